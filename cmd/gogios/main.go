@@ -4,6 +4,10 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"codeberg.org/snonux/gogios/internal"
@@ -17,6 +21,15 @@ func main() {
 	renotify := flag.Bool("renotify", false, "Renotify all unhandled")
 	force := flag.Bool("force", false, "Force sending out status")
 	version := flag.Bool("version", false, "Display version")
+	daemon := flag.Bool("daemon", false, "Run continuously with an internal scheduler")
+	graph := flag.String("graph", "", "Print the check dependency graph (dot or text) and exit")
+	graphStatus := flag.Bool("graphstatus", false, "Include the current status from the state in the graph")
+	ping := flag.String("ping", "", "Record a heartbeat of the given heartbeat check and exit")
+	submit := flag.String("submit", "", "Submit a passive check result of the given name and exit")
+	status := flag.String("status", "", "Status of the submitted result, e.g. 2 or CRITICAL")
+	output := flag.String("output", "", "Plugin output of the submitted result, may contain perf data")
+	serve := flag.Bool("serve", false, "Only serve the HTTP endpoints (e.g. heartbeat pings)")
+	agent := flag.Bool("agent", false, "Run as an agent serving the results of local checks")
 	flag.Parse()
 
 	if *version {
@@ -25,6 +38,51 @@ func main() {
 		return
 	}
 
+	if *graph != "" {
+		if err := internal.Graph(os.Stdout, *configFile, *graph, *graphStatus); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *ping != "" {
+		if err := internal.Ping(*configFile, *ping); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *submit != "" {
+		if err := internal.Submit(*configFile, *submit, *status, *output); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *serve {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+		defer stop()
+
+		internal.Serve(ctx, *configFile)
+		return
+	}
+
+	if *agent {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+		defer stop()
+
+		internal.RunAgent(ctx, *configFile)
+		return
+	}
+
+	if *daemon {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+		defer stop()
+
+		internal.RunDaemon(ctx, *configFile)
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(),
 		time.Duration(*timeout)*time.Minute)
 	defer cancel()
@@ -1,5 +1,10 @@
 module codeberg.org/snonux/gogios
 
-go 1.24
+go 1.24.0
 
-require github.com/magefile/mage v1.15.0 // indirect
+require (
+	github.com/magefile/mage v1.15.0
+	golang.org/x/crypto v0.48.0
+	golang.org/x/net v0.50.0
+	golang.org/x/sys v0.41.0
+)
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// A check result as served by the agent and pulled by the agent check type.
+type agentResult struct {
+	Status     nagiosCode
+	Output     string
+	LongOutput string   `json:"LongOutput,omitempty"`
+	PerfData   perfData `json:"PerfData,omitempty"`
+	Epoch      int64
+}
+
+// Run as an agent (replacing NRPE), which runs the configured checks on
+// request of a central Gogios and serves their results on the ListenAddr until
+// the context is done.
+func RunAgent(ctx context.Context, configFile string) {
+	conf, err := newConfig(configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := conf.sanityCheck(); err != nil {
+		log.Println("error:", err)
+	}
+	if conf.ListenAddr == "" || conf.ListenToken == "" {
+		log.Fatal("no ListenAddr or ListenToken configured")
+	}
+	sshPool := newSSHPool()
+	defer sshPool.close()
+	conf.setSSHPool(sshPool)
+
+	if err := serveHTTP(ctx, conf, conf.agentHandler()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (conf config) agentHandler() http.Handler {
+	limitCh := make(chan struct{}, max(conf.CheckConcurrency, 1))
+	invalid := conf.invalidChecks()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /check/{name...}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		check, ok := conf.Checks[name]
+		if !ok {
+			http.Error(w, "no such check '"+name+"'", http.StatusNotFound)
+			return
+		}
+
+		// The check is run right away, as with NRPE, the central Gogios
+		// takes care of retries and scheduling.
+		var result checkResult
+		if err, ok := invalid[name]; ok {
+			result = namedCheck{check, name}.invalid(err)
+		} else {
+			result = execCheck(r.Context(), limitCh, namedCheck{check, name}, conf)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(agentResult{
+			Status:     result.status,
+			Output:     result.output,
+			LongOutput: result.longOutput,
+			PerfData:   result.perfData,
+			Epoch:      result.epoch,
+		})
+	})
+	return conf.authenticate(mux)
+}
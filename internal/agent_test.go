@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAgent(t *testing.T) {
+	agentConf := config{
+		CheckTimeoutS:    10,
+		CheckConcurrency: 2,
+		ListenToken:      "secret",
+		Checks: map[string]check{
+			"Load": {
+				Plugin: "/bin/sh",
+				Args:   []string{"-c", "echo 'LOAD WARNING - load average: 6.10|load1=6.1;5;10;0'; echo 'high load'; exit 1"},
+			},
+			"Check/With Slash": {Plugin: "/bin/sh", Args: []string{"-c", "echo OK"}},
+		},
+	}
+	server := httptest.NewServer(agentConf.agentHandler())
+	defer server.Close()
+
+	tests := []checkTest{
+		{
+			name:     "result",
+			check:    check{Agent: &agentCheck{URL: server.URL, Check: "Load", Token: "secret"}},
+			expected: nagiosWarning,
+			output:   "LOAD WARNING - load average: 6.10",
+		},
+		{
+			name:     "escaped name",
+			check:    check{Agent: &agentCheck{URL: server.URL, Check: "Check/With Slash", Token: "secret"}},
+			expected: nagiosOk,
+			output:   "OK",
+		},
+		{
+			name:     "wrong token",
+			check:    check{Agent: &agentCheck{URL: server.URL, Check: "Load", Token: "wrong"}},
+			expected: nagiosCritical,
+			output:   "AGENT CRITICAL: 401 Unauthorized",
+		},
+		{
+			name:     "unknown check",
+			check:    check{Agent: &agentCheck{URL: server.URL, Check: "Disk", Token: "secret"}},
+			expected: nagiosCritical,
+			output:   "AGENT CRITICAL: 404 Not Found: no such check 'Disk'",
+		},
+		{
+			name:     "connection refused",
+			check:    check{Agent: &agentCheck{URL: "http://127.0.0.1:1", Check: "Load", Token: "secret"}},
+			expected: nagiosCritical,
+			output:   "AGENT CRITICAL: ",
+		},
+	}
+
+	runCheckTests(t, tests)
+
+	result := tests[0].check.run(context.Background(), "Load")
+	if result.longOutput != "high load" || result.perfData.String() != "load1=6.1;5;10;0" {
+		t.Errorf("unexpected long output or perf data: %q, %q", result.longOutput, result.perfData)
+	}
+}
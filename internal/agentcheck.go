@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Don't accept larger results from an agent.
+const maxAgentResultSize = 1 << 20
+
+// Pulls the result of a check run by a Gogios agent, replacing check_nrpe.
+type agentCheck struct {
+	URL      string // E.g. "https://db1.foo.zone:5667"
+	Check    string // Name of the check in the config of the agent
+	Token    string // ListenToken of the agent
+	CAFile   string `json:"CAFile,omitempty"`   // PEM roots instead of the system ones
+	Insecure bool   `json:"Insecure,omitempty"` // Skip TLS verification
+}
+
+func (a *agentCheck) validate() error {
+	if a.URL == "" || a.Check == "" || a.Token == "" {
+		return errors.New("agent check without URL, Check or Token")
+	}
+	if _, err := url.Parse(a.URL); err != nil {
+		return err
+	}
+	if _, err := loadRootCAs(a.CAFile); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *agentCheck) run(ctx context.Context) checkResult {
+	endpoint, err := url.JoinPath(a.URL, "check", a.Check)
+	if err != nil {
+		return resultf(nagiosUnknown, "AGENT UNKNOWN: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return resultf(nagiosUnknown, "AGENT UNKNOWN: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+
+	rootCAs, err := loadRootCAs(a.CAFile)
+	if err != nil {
+		return resultf(nagiosUnknown, "AGENT UNKNOWN: %v", err)
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: rootCAs, InsecureSkipVerify: a.Insecure},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return resultf(nagiosCritical, "AGENT CRITICAL: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxAgentResultSize))
+	if err != nil {
+		return resultf(nagiosCritical, "AGENT CRITICAL: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resultf(nagiosCritical, "AGENT CRITICAL: %s: %s", resp.Status,
+			strings.TrimSpace(string(body)))
+	}
+
+	var result agentResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return resultf(nagiosUnknown, "AGENT UNKNOWN: %v", err)
+	}
+	if result.Status < nagiosOk || result.Status > nagiosUnknown {
+		return resultf(nagiosUnknown, "AGENT UNKNOWN: invalid status %d", result.Status)
+	}
+	if result.Output == "" {
+		result.Output = "AGENT: No output returned from agent"
+	}
+
+	return checkResult{
+		status:     result.Status,
+		output:     result.Output,
+		longOutput: result.LongOutput,
+		perfData:   result.PerfData,
+	}
+}
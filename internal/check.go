@@ -16,6 +16,26 @@ type check struct {
 	RetryInterval int      `json:"RetryInterval,omitempty"`
 	RunInterval   int      `json:"RunInterval,omitempty"`
 	RandomSpread  int      `json:"RandomSpread,omitempty"`
+	MaxAttempts   int      `json:"MaxAttempts,omitempty"`
+	// Flap detection thresholds in percent state change, disabled if 0.
+	FlapLowThreshold  float64 `json:"FlapLowThreshold,omitempty"`
+	FlapHighThreshold float64 `json:"FlapHighThreshold,omitempty"`
+	// Built-in check types, used instead of Plugin
+	HTTP  *httpCheck  `json:"HTTP,omitempty"`
+	TLS   *tlsCheck   `json:"TLS,omitempty"`
+	Port  *portCheck  `json:"Port,omitempty"`
+	DNS   *dnsCheck   `json:"DNS,omitempty"`
+	Disk  *diskCheck  `json:"Disk,omitempty"`
+	Log   *logCheck   `json:"Log,omitempty"`
+	File  *fileCheck  `json:"File,omitempty"`
+	NRPE  *nrpeCheck  `json:"NRPE,omitempty"`
+	Agent *agentCheck `json:"Agent,omitempty"`
+	// Run the Plugin on a remote host instead
+	SSH *sshOptions `json:"SSH,omitempty"`
+	// Passive, fed by gogios -ping or the HTTP ping endpoint
+	Heartbeat *heartbeatCheck `json:"Heartbeat,omitempty"`
+	// Passive, fed by gogios -submit or the HTTP submit endpoint
+	Passive *passiveCheck `json:"Passive,omitempty"`
 }
 
 type namedCheck struct {
@@ -24,14 +44,35 @@ type namedCheck struct {
 }
 
 type checkResult struct {
-	name      string
-	output    string
-	epoch     int64
-	status    nagiosCode
-	federated bool
+	name        string
+	output      string
+	longOutput  string
+	epoch       int64
+	status      nagiosCode
+	federated   string // Origin endpoint, empty for local checks
+	passive     bool   // Submitted from outside, see passiveCheck
+	maxAttempts int    // Non-OK results in a row until it becomes a hard state
+	flapLow     float64
+	flapHigh    float64
+	perfData    perfData
+	rootCause   string // Set if unreachable
+	cached      bool   // Not executed, last state is carried over
 }
 
 func (c check) run(ctx context.Context, name string) checkResult {
+	if native := c.native(); native != nil {
+		result := c.runNative(ctx, native)
+		result.name = name
+		if result.epoch == 0 {
+			result.epoch = time.Now().Unix()
+		}
+		return result
+	}
+
+	if c.SSH != nil {
+		return c.runSSH(ctx, name)
+	}
+
 	cmd := exec.CommandContext(ctx, c.Plugin, c.Args...)
 
 	var bytes bytes.Buffer
@@ -40,31 +81,83 @@ func (c check) run(ctx context.Context, name string) checkResult {
 
 	if err := cmd.Run(); err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
-			return checkResult{name, "Check command timed out", time.Now().Unix(), nagiosCritical, false}
+			return timedOut(name)
 		}
 	}
 
-	// Remove Nagios perf data from output and trim whitespaces
-	parts := strings.Split(bytes.String(), "|")
-	output := strings.TrimSpace(parts[0])
+	return pluginResult(name, bytes.String(), cmd.ProcessState.ExitCode())
+}
+
+func timedOut(name string) checkResult {
+	return checkResult{
+		name:   name,
+		output: "Check command timed out",
+		epoch:  time.Now().Unix(),
+		status: nagiosCritical,
+	}
+}
+
+// Evaluate the output and exit code of a plugin, run locally or remotely.
+func pluginResult(name, rawOutput string, ec int) checkResult {
+	// Separate Nagios perf data from output
+	output, longOutput, perfData := parsePluginOutput(rawOutput)
 
-	ec := cmd.ProcessState.ExitCode()
 	if ec < int(nagiosOk) || ec > int(nagiosUnknown) {
 		// If the exit code is not in the range of known Nagios codes, treat it as unknown
 		ec = int(nagiosUnknown)
 	}
 
-	return checkResult{name, output, time.Now().Unix(), nagiosCode(ec), false}
+	return checkResult{
+		name:       name,
+		output:     output,
+		longOutput: longOutput,
+		epoch:      time.Now().Unix(),
+		status:     nagiosCode(ec),
+		perfData:   perfData,
+	}
 }
 
 func (c check) skip(name, output string) checkResult {
-	return checkResult{name, output, time.Now().Unix(), nagiosUnknown, false}
+	return checkResult{
+		name:   name,
+		output: output,
+		epoch:  time.Now().Unix(),
+		status: nagiosUnknown,
+	}
+}
+
+// The check wasn't run, as a check it depends on (the root cause) is not OK.
+func (c check) unreachable(name, rootCause, output string) checkResult {
+	return checkResult{
+		name:      name,
+		output:    output,
+		epoch:     time.Now().Unix(),
+		status:    nagiosUnreachable,
+		rootCause: rootCause,
+	}
 }
 
 func (c namedCheck) run(ctx context.Context) checkResult {
-	return c.check.run(ctx, c.name)
+	return c.annotate(c.check.run(ctx, c.name))
 }
 
 func (c namedCheck) skip(output string) checkResult {
-	return c.check.skip(c.name, output)
-}
\ No newline at end of file
+	return c.annotate(c.check.skip(c.name, output))
+}
+
+// The check isn't run, as its config is invalid.
+func (c namedCheck) invalid(err error) checkResult {
+	return c.skip("invalid check config, not run: " + strings.ReplaceAll(err.Error(), "\n", "; "))
+}
+
+func (c namedCheck) unreachable(rootCause, output string) checkResult {
+	return c.annotate(c.check.unreachable(c.name, rootCause, output))
+}
+
+// Add the check config needed by state.update to evaluate the result.
+func (c namedCheck) annotate(result checkResult) checkResult {
+	result.maxAttempts = c.MaxAttempts
+	result.flapLow = c.FlapLowThreshold
+	result.flapHigh = c.FlapHighThreshold
+	return result
+}
@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// A table-driven test case, shared by the tests of all check types.
+type checkTest struct {
+	name     string
+	check    check
+	expected nagiosCode
+	output   string // Expected to be contained in the output
+}
+
+// Validate and run the checks, each as a subtest.
+func runCheckTests(t *testing.T, tests []checkTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.check.validate(); err != nil {
+				t.Fatal(err)
+			}
+			expectResult(t, tt.check.run(context.Background(), tt.name), tt.expected, tt.output)
+		})
+	}
+}
+
+func expectResult(t *testing.T, result checkResult, expected nagiosCode, output string) {
+	t.Helper()
+	if result.status != expected {
+		t.Errorf("expected %s, got %s: %s", expected.Str(), result.status.Str(), result.output)
+	}
+	if !strings.Contains(result.output, output) {
+		t.Errorf("expected output to contain %q, got %q", output, result.output)
+	}
+}
@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Runs a command with the report body on stdin and the subject in the
+// GOGIOS_SUBJECT environment variable, e.g. to send it via a pager gateway.
+type commandNotifier struct {
+	Enable   bool
+	Command  string
+	Args     []string `json:"Args,omitempty"`
+	TimeoutS int      `json:"TimeoutS,omitempty"` // Default 30
+}
+
+func (c *commandNotifier) name() string {
+	return "command"
+}
+
+func (c *commandNotifier) enabled() bool {
+	return c.Enable
+}
+
+func (c *commandNotifier) validate() error {
+	if c.Command == "" {
+		return errors.New("command notifier without Command")
+	}
+	return nil
+}
+
+func (c *commandNotifier) notify(n notification) error {
+	timeout := time.Duration(c.TimeoutS) * time.Second
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c.Command, c.Args...)
+	cmd.Env = append(os.Environ(), "GOGIOS_SUBJECT="+n.Subject)
+	cmd.Stdin = strings.NewReader(n.Body)
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%s timed out", c.Command)
+		}
+		return fmt.Errorf("%s: %w: %s", c.Command, err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
@@ -2,23 +2,37 @@ package internal
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"os"
+	"slices"
 )
 
 type config struct {
 	EmailTo          string
 	EmailFrom        string
 	SMTPServer       string `json:"SMTPServer,omitempty"`
-	SMTPDisable      bool   `json:"SMTPDisable,omitempty"` // TODO: Document this option
+	SMTPDisable      bool   `json:"SMTPDisable,omitempty"`
 	StateDir         string `json:"StateDir,omitempty"`
 	CheckTimeoutS    int
 	CheckConcurrency int
 	StaleThreshold   int      `json:"StaleThreshold,omitempty"`
 	Federated        []string `json:"Federated,omitempty"` // TODO: Document this option
-	Checks           map[string]check
+	LockMode         string   `json:"LockMode,omitempty"`
+	// HTTP endpoints, served in daemon mode, with gogios -serve or -agent
+	ListenAddr     string `json:"ListenAddr,omitempty"`
+	ListenToken    string `json:"ListenToken,omitempty"`    // Bearer token, if set
+	ListenCertFile string `json:"ListenCertFile,omitempty"` // Serve HTTPS, if set
+	ListenKeyFile  string `json:"ListenKeyFile,omitempty"`
+	// Only used in daemon mode
+	DaemonIntervalS        int `json:"DaemonIntervalS,omitempty"`
+	DaemonPersistIntervalS int `json:"DaemonPersistIntervalS,omitempty"`
+	// Channels delivering the reports, SMTP via EmailTo by default
+	Notifiers notifiers `json:"Notifiers,omitempty"`
+	Checks    map[string]check
 }
 
 func newConfig(configFile string) (config, error) {
@@ -49,25 +63,97 @@ func newConfig(configFile string) (config, error) {
 		log.Println("Set SMTPServer to " + conf.SMTPServer)
 	}
 
+	if conf.Notifiers.SMTP == nil {
+		conf.Notifiers.SMTP = &smtpNotifier{Enable: !conf.SMTPDisable && conf.EmailTo != ""}
+	}
+	if conf.Notifiers.SMTP.To == "" {
+		conf.Notifiers.SMTP.To = conf.EmailTo
+	}
+	if conf.Notifiers.SMTP.From == "" {
+		conf.Notifiers.SMTP.From = conf.EmailFrom
+	}
+	if conf.Notifiers.SMTP.Server == "" {
+		conf.Notifiers.SMTP.Server = conf.SMTPServer
+	}
+
 	if conf.StateDir == "" {
 		conf.StateDir = "."
 		log.Println("Set StateDir to " + conf.StateDir)
 	}
 
+	for name, check := range conf.Checks {
+		if stateful, ok := check.native().(statefulCheck); ok {
+			stateful.setStateFile(checkStateFile(conf.StateDir, name))
+		}
+	}
+
 	if conf.StaleThreshold == 0 {
 		conf.StaleThreshold = 3600 // Default to 1 hour
 	}
 
+	if conf.LockMode == "" {
+		conf.LockMode = lockModeSkip
+	}
+
+	if conf.DaemonIntervalS == 0 {
+		conf.DaemonIntervalS = 300 // Default to 5 minutes
+	}
+
+	if conf.DaemonPersistIntervalS == 0 {
+		conf.DaemonPersistIntervalS = 60
+	}
+
 	return conf, nil
 }
 
+// Validate the config, returning all problems found.
 func (conf config) sanityCheck() error {
+	var errs []error
+
+	switch conf.LockMode {
+	case lockModeWait, lockModeSkip, lockModeAlert:
+	default:
+		errs = append(errs, fmt.Errorf("unknown LockMode '%s'", conf.LockMode))
+	}
+
+	if (conf.ListenCertFile == "") != (conf.ListenKeyFile == "") {
+		errs = append(errs, errors.New("only one of ListenCertFile and ListenKeyFile configured"))
+	}
+
+	for _, n := range conf.Notifiers.all() {
+		if !n.enabled() {
+			continue
+		}
+		if err := n.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	invalid := conf.invalidChecks()
+	for _, name := range slices.Sorted(maps.Keys(invalid)) {
+		errs = append(errs, invalid[name])
+	}
+	return errors.Join(errs...)
+}
+
+// The checks with an invalid config, along with the problems found. They
+// aren't run, so that a broken check can't crash Gogios or hang the run, but
+// all other checks run as usual.
+func (conf config) invalidChecks() map[string]error {
+	invalid := conf.dependencyErrors()
+
 	for name, check := range conf.Checks {
-		for _, depName := range check.DependsOn {
-			if _, ok := conf.Checks[depName]; !ok {
-				return fmt.Errorf("check '%s' depends on non existant check '%s'", name, depName)
-			}
+		var errs []error
+		if check.FlapLowThreshold > check.FlapHighThreshold {
+			errs = append(errs, fmt.Errorf("check '%s' has FlapLowThreshold above FlapHighThreshold", name))
+		}
+		if err := check.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("check '%s': %w", name, err))
+		}
+		if len(errs) > 0 {
+			invalid[name] = errors.Join(append(errs, invalid[name])...)
 		}
 	}
-	return nil
+
+	return invalid
 }
@@ -0,0 +1,251 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type daemon struct {
+	conf     config
+	mu       sync.Mutex // Guards state
+	state    state
+	limitCh  chan struct{}
+	resultCh chan checkResult
+}
+
+// Run Gogios as a long-running process, which schedules every check on its
+// own according to its interval and notifies status changes as they arrive.
+func RunDaemon(ctx context.Context, configFile string) {
+	conf, err := newConfig(configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := conf.sanityCheck(); err != nil {
+		notifyError(conf, err)
+	}
+
+	lock := takeRunLock(ctx, conf)
+	if lock == nil {
+		return
+	}
+	defer lock.release()
+
+	state, err := newState(conf)
+	if err != nil {
+		notifyError(conf, err)
+	}
+
+	if conf.ListenAddr != "" {
+		go func() {
+			if err := serveHTTP(ctx, conf, conf.httpHandler()); err != nil {
+				notifyError(conf, err)
+			}
+		}()
+	}
+
+	d := &daemon{
+		conf:     conf,
+		state:    state,
+		limitCh:  make(chan struct{}, conf.CheckConcurrency),
+		resultCh: make(chan checkResult),
+	}
+	d.run(ctx)
+}
+
+func (d *daemon) run(ctx context.Context) {
+	sshPool := newSSHPool()
+	d.conf.setSSHPool(sshPool)
+
+	invalid := d.conf.invalidChecks()
+	var wg sync.WaitGroup
+	for name, check := range d.conf.Checks {
+		wg.Add(1)
+		go func(check namedCheck) {
+			defer wg.Done()
+			if err, ok := invalid[check.name]; ok {
+				d.resultCh <- check.invalid(err)
+				return
+			}
+			d.schedule(ctx, check)
+		}(namedCheck{check, name})
+	}
+
+	go func() {
+		wg.Wait()
+		close(d.resultCh)
+	}()
+
+	ticker := time.NewTicker(time.Duration(d.conf.DaemonPersistIntervalS) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case result, ok := <-d.resultCh:
+			if !ok {
+				log.Println("All checks stopped, shutting down")
+				sshPool.close()
+				d.persist()
+				return
+			}
+			d.mu.Lock()
+			d.state.update(result)
+			n, doNotify := d.changes()
+			d.mu.Unlock()
+			if doNotify {
+				d.notify(n)
+			}
+		case <-ticker.C:
+			d.mergeFederated(ctx)
+			d.persist()
+		}
+	}
+}
+
+// Run the check over and over again until the context is done.
+func (d *daemon) schedule(ctx context.Context, check namedCheck) {
+	interval := time.Duration(d.conf.DaemonIntervalS) * time.Second
+	if check.RunInterval > 0 {
+		interval = time.Duration(check.RunInterval) * time.Second
+	}
+
+	// Don't run checks earlier than their interval after a restart.
+	d.mu.Lock()
+	delay := max(interval-d.state.age(check.name), 0)
+	if _, ok := d.state.checks[check.name]; !ok {
+		delay = 0
+	}
+	d.mu.Unlock()
+
+	for {
+		delay += spread(check)
+		log.Printf("Scheduling %s in %v", check.name, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		result := d.runCheck(ctx, check)
+		if ctx.Err() != nil {
+			return // Result of an interrupted check is meaningless
+		}
+		d.resultCh <- result
+		delay = interval
+	}
+}
+
+func spread(check namedCheck) time.Duration {
+	if check.RandomSpread <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Intn(check.RandomSpread)) * time.Second
+}
+
+func (d *daemon) runCheck(ctx context.Context, check namedCheck) checkResult {
+	if rootCause, err := d.dependenciesOk(check.name, check.DependsOn); err != nil {
+		return check.unreachable(rootCause, err.Error())
+	}
+
+	for retries := check.Retries; ; retries-- {
+		result := execCheck(ctx, d.limitCh, check, d.conf)
+		if result.status == nagiosOk || retries <= 0 {
+			return result
+		}
+
+		retryDuration := time.Duration(check.RetryInterval) * time.Second
+		select {
+		case <-ctx.Done():
+			return result
+		case <-time.After(retryDuration):
+		}
+		log.Printf("Retrying %s after %v", check.name, retryDuration)
+	}
+}
+
+// Unlike in a single run, dependencies don't need to be waited for, as the
+// last known status of every check is in the state. If a dependency is not
+// OK, the root cause of it is returned along with the error.
+func (d *daemon) dependenciesOk(name string, dependencies []string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, dep := range dependencies {
+		cs, ok := d.state.checks[dep]
+		if !ok {
+			if _, ok := d.conf.Checks[dep]; !ok {
+				warnUnknownDependency(name, dep)
+			}
+			continue
+		}
+		switch cs.Status {
+		case nagiosCritical:
+			return dep, dependencyError(dep, dep)
+		case nagiosUnreachable:
+			return cs.RootCause, dependencyError(dep, cs.RootCause)
+		}
+	}
+	return "", nil
+}
+
+func (d *daemon) mergeFederated(ctx context.Context) {
+	if len(d.conf.Federated) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(d.conf.CheckTimeoutS)*time.Second)
+	defer cancel()
+	fetched := fetchFederated(ctx, d.conf)
+
+	d.mu.Lock()
+	d.state = d.state.mergeFetched(fetched)
+	n, doNotify := d.changes()
+	d.mu.Unlock()
+
+	if doNotify {
+		d.notify(n)
+	}
+}
+
+// The notification of the changes since the last call, if any. It is a copy,
+// to be sent with d.notify after releasing the lock, as notifiers may take a
+// while (e.g. webhook retries). Caller must hold the lock.
+func (d *daemon) changes() (notification, bool) {
+	d.state.staleEpoch = time.Now().Unix() - int64(d.conf.StaleThreshold)
+
+	subject, body, doNotify := d.state.report(false, false)
+	var n notification
+	if doNotify {
+		n = notification{Subject: subject, Body: body, Changes: d.state.changes()}
+	}
+	d.state.acknowledge()
+	return n, doNotify
+}
+
+// Caller must not hold the lock.
+func (d *daemon) notify(n notification) {
+	if err := notify(d.conf, n); err != nil {
+		log.Println("error:", err)
+	}
+}
+
+func (d *daemon) persist() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.state.persist(); err != nil {
+		notifyError(d.conf, err)
+	}
+
+	d.state.staleEpoch = time.Now().Unix() - int64(d.conf.StaleThreshold)
+	subject, body, _ := d.state.report(false, false)
+	if err := persistReport(subject, body, d.conf); err != nil {
+		notifyError(d.conf, err)
+	}
+}
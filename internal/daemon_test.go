@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Collects the reports posted to a webhook.
+type webhookRecorder struct {
+	mu       sync.Mutex
+	payloads []webhookPayload
+}
+
+func (rec *webhookRecorder) serve(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		rec.mu.Lock()
+		rec.payloads = append(rec.payloads, payload)
+		rec.mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// The changes of all reports posted since the last call.
+func (rec *webhookRecorder) changes() []checkChange {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	var changes []checkChange
+	for _, payload := range rec.payloads {
+		changes = append(changes, payload.Changes...)
+	}
+	rec.payloads = nil
+	return changes
+}
+
+func TestDaemonRun(t *testing.T) {
+	var rec webhookRecorder
+	conf := config{
+		StateDir:               t.TempDir(),
+		CheckTimeoutS:          5,
+		CheckConcurrency:       2,
+		StaleThreshold:         3600,
+		DaemonIntervalS:        3600,
+		DaemonPersistIntervalS: 3600,
+		Notifiers:              notifiers{Webhook: &webhookNotifier{Enable: true, URL: rec.serve(t).URL}},
+		Checks: map[string]check{
+			"Check Fail": {Plugin: "/bin/sh", Args: []string{"-c", "echo FAIL; exit 2"}},
+		},
+	}
+	s, err := newState(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &daemon{
+		conf:     conf,
+		state:    s,
+		limitCh:  make(chan struct{}, conf.CheckConcurrency),
+		resultCh: make(chan checkResult),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.run(ctx)
+		close(done)
+	}()
+
+	var changes []checkChange
+	for deadline := time.Now().Add(5 * time.Second); len(changes) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		changes = rec.changes()
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon didn't shut down")
+	}
+
+	if len(changes) == 0 || changes[0].Name != "Check Fail" || changes[0].Status != nagiosCritical.Str() {
+		t.Errorf("expected the failing check to be notified, got %+v", changes)
+	}
+
+	// The state is persisted on shutdown.
+	s, err = newState(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs := s.checks["Check Fail"]; cs.Status != nagiosCritical || cs.Output != "FAIL" {
+		t.Errorf("unexpected persisted state: %+v", cs)
+	}
+}
+
+func TestDaemonMergeFederated(t *testing.T) {
+	var (
+		rec    webhookRecorder
+		d      *daemon
+		mu     sync.Mutex
+		remote map[string]checkState
+	)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The state isn't locked while fetching from the endpoints.
+		locked := make(chan struct{})
+		go func() {
+			d.mu.Lock()
+			d.mu.Unlock()
+			close(locked)
+		}()
+		select {
+		case <-locked:
+		case <-time.After(5 * time.Second):
+			t.Error("state locked while querying the federated endpoint")
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		json.NewEncoder(w).Encode(stateFile{Version: stateVersion, Checks: remote})
+	}))
+	defer endpoint.Close()
+
+	setRemote := func(checks map[string]checkState) {
+		mu.Lock()
+		remote = checks
+		mu.Unlock()
+	}
+	// Another daemon, which acknowledged its changes already.
+	acknowledged := func(status nagiosCode) checkState {
+		return checkState{Status: status, PrevStatus: status, HardStatus: status, Epoch: time.Now().Unix()}
+	}
+
+	d = &daemon{
+		conf: config{
+			CheckTimeoutS:  5,
+			StaleThreshold: 3600,
+			Federated:      []string{endpoint.URL},
+			Notifiers:      notifiers{Webhook: &webhookNotifier{Enable: true, URL: rec.serve(t).URL}},
+		},
+		state: state{checks: make(map[string]checkState)},
+	}
+	ctx := context.Background()
+
+	setRemote(map[string]checkState{"Remote Check": acknowledged(nagiosOk)})
+	d.mergeFederated(ctx)
+	if changes := rec.changes(); len(changes) != 2 {
+		t.Errorf("expected the new remote and endpoint checks to be notified, got %+v", changes)
+	}
+
+	setRemote(map[string]checkState{"Remote Check": acknowledged(nagiosCritical)})
+	d.mergeFederated(ctx)
+	changes := rec.changes()
+	if len(changes) != 1 || changes[0].Name != "Remote Check" ||
+		changes[0].Status != nagiosCritical.Str() || changes[0].PrevStatus != nagiosOk.Str() {
+		t.Errorf("expected the remote change to be notified, got %+v", changes)
+	}
+
+	// Notified once only, even though the remote state didn't change.
+	d.mergeFederated(ctx)
+	if changes := rec.changes(); len(changes) != 0 {
+		t.Errorf("expected no changes to be notified again, got %+v", changes)
+	}
+
+	// Removed on the remote, so removed here as well.
+	setRemote(map[string]checkState{})
+	d.mergeFederated(ctx)
+	if _, ok := d.state.checks["Remote Check"]; ok {
+		t.Error("expected the state of the removed remote check to be removed")
+	}
+	if _, ok := d.state.checks["Federated endpoint "+endpoint.URL]; !ok {
+		t.Error("expected the state of the endpoint check to be kept")
+	}
+}
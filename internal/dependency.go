@@ -3,49 +3,108 @@ package internal
 import (
 	"context"
 	"fmt"
+	"log"
 )
 
 type dependency struct {
 	okMap  map[string]chan struct{}
 	nokMap map[string]chan struct{}
+	// Set before the nokMap channel is closed, so it's safe to read after.
+	rootCauses map[string]*string
 }
 
-func newDependency(conf config) dependency {
+func newDependency(conf config, state state) dependency {
 	d := dependency{
-		okMap:  make(map[string]chan struct{}, len(conf.Checks)),
-		nokMap: make(map[string]chan struct{}, len(conf.Checks)),
+		okMap:      make(map[string]chan struct{}, len(conf.Checks)),
+		nokMap:     make(map[string]chan struct{}, len(conf.Checks)),
+		rootCauses: make(map[string]*string, len(conf.Checks)),
 	}
 
-	for name := range conf.Checks {
-		d.okMap[name] = make(chan struct{})
-		d.nokMap[name] = make(chan struct{})
+	for name, check := range conf.Checks {
+		d.add(name)
+
+		for _, depName := range check.DependsOn {
+			if _, ok := conf.Checks[depName]; ok {
+				continue
+			}
+			if _, ok := d.okMap[depName]; ok {
+				continue
+			}
+			// Federated checks aren't run here, so go with their last
+			// known status.
+			cs, ok := state.checks[depName]
+			if !ok || cs.Federated == "" {
+				warnUnknownDependency(name, depName)
+				continue
+			}
+			d.add(depName)
+			d.resolve(depName, cs.Status, cs.RootCause)
+		}
 	}
 
 	return d
 }
 
+// With federated endpoints configured, dependencies on unknown checks pass
+// the config validation, as they may be federated checks. If they aren't in
+// the merged local and federated state either, they are most likely typos.
+func warnUnknownDependency(name, depName string) {
+	log.Printf("Warning: check '%s' depends on '%s', which is neither a local nor a federated check", name, depName)
+}
+
+func (d dependency) add(name string) {
+	d.okMap[name] = make(chan struct{})
+	d.nokMap[name] = make(chan struct{})
+	d.rootCauses[name] = new(string)
+}
+
 func (d dependency) ok(name string) {
 	close(d.okMap[name])
 }
 
-func (d dependency) notOk(name string) {
+// The root cause is the check which made this one fail, which is the check
+// itself unless it is unreachable.
+func (d dependency) notOk(name, rootCause string) {
+	*d.rootCauses[name] = rootCause
 	close(d.nokMap[name])
 }
 
-// Wait for all dependant checks to be executed!
-func (d dependency) wait(ctx context.Context, dependencies []string) error {
+// Resolve the dependency according to the status of the check.
+func (d dependency) resolve(name string, status nagiosCode, rootCause string) {
+	switch status {
+	case nagiosCritical:
+		d.notOk(name, name)
+	case nagiosUnreachable:
+		d.notOk(name, rootCause)
+	default:
+		d.ok(name)
+	}
+}
+
+// Wait for all dependant checks to be executed! If a dependency is not OK,
+// the root cause of it is returned along with the error.
+func (d dependency) wait(ctx context.Context, dependencies []string) (string, error) {
 	for _, dep := range dependencies {
 		if _, ok := d.okMap[dep]; !ok {
-			// We sent an error mail already via config.sanityCheck for this case.
+			// Either reported by config.sanityCheck or warned about by
+			// newDependency.
 			continue
 		}
 		select {
 		case <-d.okMap[dep]:
 		case <-d.nokMap[dep]:
-			return fmt.Errorf("dependency '%s' is not OK!", dep)
+			rootCause := *d.rootCauses[dep]
+			return rootCause, dependencyError(dep, rootCause)
 		case <-ctx.Done():
-			return fmt.Errorf("waited for too long for dependency '%s': %w", dep, ctx.Err())
+			return "", fmt.Errorf("waited for too long for dependency '%s': %w", dep, ctx.Err())
 		}
 	}
-	return nil
+	return "", nil
+}
+
+func dependencyError(dep, rootCause string) error {
+	if dep == rootCause {
+		return fmt.Errorf("dependency '%s' is not OK!", dep)
+	}
+	return fmt.Errorf("dependency '%s' is not OK! (root cause '%s')", dep, rootCause)
 }
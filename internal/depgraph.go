@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Validate the dependency graph of all checks, returning the problems found
+// per check. Dependencies on unknown checks are fine if there are federated
+// endpoints, as they may be federated checks (newDependency warns about those
+// not in the federated state either).
+func (conf config) dependencyErrors() map[string]error {
+	errs := make(map[string][]error)
+
+	for _, name := range conf.checkNames() {
+		check := conf.Checks[name]
+		for _, depName := range check.DependsOn {
+			_, ok := conf.Checks[depName]
+			switch {
+			case !ok && len(conf.Federated) == 0:
+				errs[name] = append(errs[name], fmt.Errorf("check '%s' depends on non existant check '%s'", name, depName))
+			case depName == name:
+				errs[name] = append(errs[name], fmt.Errorf("check '%s' depends on itself", name))
+			}
+		}
+	}
+
+	for _, cycle := range conf.dependencyCycles() {
+		for _, name := range cycle[1:] {
+			errs[name] = append(errs[name], fmt.Errorf("check '%s' is in the dependency cycle %s",
+				name, strings.Join(cycle, " -> ")))
+		}
+	}
+
+	joined := make(map[string]error, len(errs))
+	for name, checkErrs := range errs {
+		joined[name] = errors.Join(checkErrs...)
+	}
+	return joined
+}
+
+// Find all dependency cycles (self-dependencies aside) via depth-first search.
+// Every cycle is returned as a path starting and ending with the same check.
+func (conf config) dependencyCycles() [][]string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	var (
+		cycles [][]string
+		marks  = make(map[string]int, len(conf.Checks))
+		path   []string
+	)
+
+	var visit func(name string)
+	visit = func(name string) {
+		marks[name] = visiting
+		path = append(path, name)
+
+		for _, depName := range conf.Checks[name].DependsOn {
+			if _, ok := conf.Checks[depName]; !ok || depName == name {
+				continue
+			}
+			switch marks[depName] {
+			case unvisited:
+				visit(depName)
+			case visiting:
+				start := slices.Index(path, depName)
+				cycle := append(slices.Clone(path[start:]), depName)
+				cycles = append(cycles, cycle)
+			}
+		}
+
+		path = path[:len(path)-1]
+		marks[name] = visited
+	}
+
+	for _, name := range conf.checkNames() {
+		if marks[name] == unvisited {
+			visit(name)
+		}
+	}
+
+	return cycles
+}
+
+// Order the checks so that every check comes after all checks it depends on.
+// Checks in a cycle can't be ordered and are appended at the end.
+func (conf config) topologicalOrder() []string {
+	var (
+		order      = make([]string, 0, len(conf.Checks))
+		numDeps    = make(map[string]int, len(conf.Checks))
+		dependents = make(map[string][]string, len(conf.Checks))
+		ready      []string
+	)
+
+	for _, name := range conf.checkNames() {
+		for _, depName := range conf.Checks[name].DependsOn {
+			if _, ok := conf.Checks[depName]; !ok {
+				continue
+			}
+			numDeps[name]++
+			dependents[depName] = append(dependents[depName], name)
+		}
+		if numDeps[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	for len(ready) > 0 {
+		name := ready[0]
+		ready = ready[1:]
+		order = append(order, name)
+
+		for _, dependent := range dependents[name] {
+			numDeps[dependent]--
+			if numDeps[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	for _, name := range conf.checkNames() {
+		if numDeps[name] > 0 {
+			order = append(order, name)
+		}
+	}
+
+	return order
+}
+
+func (conf config) checkNames() []string {
+	names := make([]string, 0, len(conf.Checks))
+	for name := range conf.Checks {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
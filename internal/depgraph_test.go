@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDependencyErrors(t *testing.T) {
+	conf := config{Checks: map[string]check{
+		"A": {DependsOn: []string{"B"}},
+		"B": {DependsOn: []string{"C"}},
+		"C": {DependsOn: []string{"A"}},
+		"D": {DependsOn: []string{"D"}},
+		"E": {DependsOn: []string{"Missing"}},
+	}}
+
+	errs := conf.dependencyErrors()
+	for name, expected := range map[string]string{
+		"A": "check 'A' is in the dependency cycle A -> B -> C -> A",
+		"B": "check 'B' is in the dependency cycle A -> B -> C -> A",
+		"C": "check 'C' is in the dependency cycle A -> B -> C -> A",
+		"D": "check 'D' depends on itself",
+		"E": "check 'E' depends on non existant check 'Missing'",
+	} {
+		if err := errs[name]; err == nil || err.Error() != expected {
+			t.Errorf("expected error %q for %s, got %v", expected, name, err)
+		}
+	}
+
+	conf = config{
+		Federated: []string{"http://example.org/state.json"},
+		Checks: map[string]check{
+			"A": {RunInterval: 300},
+			"B": {DependsOn: []string{"A", "Federated Check"}},
+		},
+	}
+	if errs := conf.dependencyErrors(); len(errs) > 0 {
+		t.Errorf("expected no errors, got %v", errs)
+	}
+}
+
+func TestTopologicalOrder(t *testing.T) {
+	conf := config{Checks: map[string]check{
+		"HTTP":  {DependsOn: []string{"Ping", "DNS"}},
+		"DNS":   {DependsOn: []string{"Ping"}},
+		"Ping":  {},
+		"Other": {},
+		"X":     {DependsOn: []string{"Y"}},
+		"Y":     {DependsOn: []string{"X"}},
+	}}
+
+	expected := []string{"Other", "Ping", "DNS", "HTTP", "X", "Y"}
+	if order := conf.topologicalOrder(); !slices.Equal(order, expected) {
+		t.Errorf("expected order %v, got %v", expected, order)
+	}
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Built-in filesystem usage check of the local host, replacing check_disk.
+type diskCheck struct {
+	Mounts           []string `json:"Mounts,omitempty"`       // Mount point globs to check, default all
+	Exclude          []string `json:"Exclude,omitempty"`      // Mount point globs to skip
+	ExcludeTypes     []string `json:"ExcludeTypes,omitempty"` // Filesystem type globs to skip, e.g. "tmpfs"
+	WarnPercent      float64  `json:"WarnPercent,omitempty"`  // Used space, default 80
+	CritPercent      float64  `json:"CritPercent,omitempty"`  // Default 90
+	WarnInodePercent float64  `json:"WarnInodePercent,omitempty"`
+	CritInodePercent float64  `json:"CritInodePercent,omitempty"`
+}
+
+// A mounted filesystem, sizes are in blocks.
+type filesystem struct {
+	mount, fsType         string
+	blocks, bfree, bavail uint64
+	files, ffree          uint64
+	unresponsive          bool // Not statted in time, e.g. a hung network filesystem
+}
+
+// Used space in percent as df(1) calculates it, excluding the reserved blocks.
+func (fs filesystem) usedPercent() float64 {
+	used := fs.blocks - fs.bfree
+	if used+fs.bavail == 0 {
+		return 0
+	}
+	return float64(used) * 100 / float64(used+fs.bavail)
+}
+
+// Returns false if the filesystem has no inode information.
+func (fs filesystem) inodePercent() (float64, bool) {
+	if fs.files == 0 {
+		return 0, false
+	}
+	return float64(fs.files-fs.ffree) * 100 / float64(fs.files), true
+}
+
+func (d *diskCheck) validate() error {
+	for _, patterns := range [][]string{d.Mounts, d.Exclude, d.ExcludeTypes} {
+		for _, pattern := range patterns {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (d *diskCheck) run(ctx context.Context) checkResult {
+	filesystems, err := listFilesystems(ctx, d.excluded)
+	if err != nil {
+		return resultf(nagiosUnknown, "DISK UNKNOWN: %v", err)
+	}
+	return d.evaluate(filesystems)
+}
+
+func (d *diskCheck) evaluate(filesystems []filesystem) checkResult {
+	warn, crit := d.WarnPercent, d.CritPercent
+	if warn == 0 {
+		warn = 80
+	}
+	if crit == 0 {
+		crit = 90
+	}
+
+	var (
+		status   nagiosCode
+		problems []string
+		details  []string
+		data     perfData
+		zero     = 0.0
+		hundred  = 100.0
+	)
+
+	for _, fs := range d.filter(filesystems) {
+		if fs.unresponsive {
+			detail := fmt.Sprintf("%s not responding", fs.mount)
+			problems = append(problems, detail)
+			details = append(details, detail)
+			status = nagiosCritical
+			continue
+		}
+
+		used := fs.usedPercent()
+		fsStatus := thresholdStatus(used, warn, crit)
+		detail := fmt.Sprintf("%s %.1f%% used", fs.mount, used)
+		data = append(data, perfDatum{Label: fs.mount, Value: roundPercent(used), UOM: "%",
+			Warn: formatThreshold(warn), Crit: formatThreshold(crit), Min: &zero, Max: &hundred})
+
+		if inodes, ok := fs.inodePercent(); ok {
+			inodeStatus := thresholdStatus(inodes, d.WarnInodePercent, d.CritInodePercent)
+			detail += fmt.Sprintf(", %.1f%% inodes used", inodes)
+			data = append(data, perfDatum{Label: fs.mount + " inodes", Value: roundPercent(inodes), UOM: "%",
+				Warn: formatThreshold(d.WarnInodePercent), Crit: formatThreshold(d.CritInodePercent),
+				Min: &zero, Max: &hundred})
+			fsStatus = max(fsStatus, inodeStatus)
+		}
+
+		if fsStatus != nagiosOk {
+			problems = append(problems, detail)
+		}
+		status = max(status, fsStatus)
+		details = append(details, detail)
+	}
+
+	if len(details) == 0 {
+		return resultf(nagiosUnknown, "DISK UNKNOWN: no filesystems to check")
+	}
+
+	output := fmt.Sprintf("DISK %s - %d filesystems checked", status.Str(), len(details))
+	if len(problems) > 0 {
+		output = fmt.Sprintf("DISK %s - %s", status.Str(), strings.Join(problems, "; "))
+	}
+	return checkResult{
+		status:     status,
+		output:     output,
+		longOutput: strings.Join(details, "\n"),
+		perfData:   data,
+	}
+}
+
+// Apply the include and exclude patterns and skip pseudo filesystems without
+// any blocks (e.g. proc). Unresponsive filesystems are kept, their size is
+// unknown.
+func (d *diskCheck) filter(filesystems []filesystem) (filtered []filesystem) {
+	for _, fs := range filesystems {
+		if (fs.blocks > 0 || fs.unresponsive) && !d.excluded(fs.mount, fs.fsType) {
+			filtered = append(filtered, fs)
+		}
+	}
+
+	slices.SortFunc(filtered, func(a, b filesystem) int { return strings.Compare(a.mount, b.mount) })
+	return slices.CompactFunc(filtered, func(a, b filesystem) bool { return a.mount == b.mount })
+}
+
+// Excluded mounts aren't even statted where possible, so a hung network
+// filesystem can be excluded from the check.
+func (d *diskCheck) excluded(mount, fsType string) bool {
+	matchAny := func(patterns []string, str string) bool {
+		return slices.ContainsFunc(patterns, func(pattern string) bool {
+			ok, _ := filepath.Match(pattern, str)
+			return ok
+		})
+	}
+
+	return len(d.Mounts) > 0 && !matchAny(d.Mounts, mount) ||
+		matchAny(d.Exclude, mount) || matchAny(d.ExcludeTypes, fsType)
+}
+
+func roundPercent(percent float64) float64 {
+	return math.Round(percent*100) / 100
+}
@@ -0,0 +1,41 @@
+//go:build darwin || freebsd || openbsd
+
+package internal
+
+import (
+	"context"
+	"syscall"
+)
+
+// MNT_NOWAIT, the same on all BSDs: Don't block on unresponsive filesystems.
+const mntNoWait = 2
+
+// With MNT_NOWAIT, there is neither a need to honor the context nor to skip
+// excluded mounts early.
+func listFilesystems(context.Context, func(mount, fsType string) bool) ([]filesystem, error) {
+	n, err := syscall.Getfsstat(nil, mntNoWait)
+	if err != nil {
+		return nil, err
+	}
+	stats := make([]syscall.Statfs_t, n)
+	if n, err = syscall.Getfsstat(stats, mntNoWait); err != nil {
+		return nil, err
+	}
+
+	filesystems := make([]filesystem, n)
+	for i, stat := range stats[:n] {
+		filesystems[i] = statfsFilesystem(stat)
+	}
+	return filesystems, nil
+}
+
+func int8String(chars []int8) string {
+	bytes := make([]byte, 0, len(chars))
+	for _, c := range chars {
+		if c == 0 {
+			break
+		}
+		bytes = append(bytes, byte(c))
+	}
+	return string(bytes)
+}
@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// How long to wait for the mounts to be statted. statfs blocks on unresponsive
+// network filesystems, which are then reported as not responding.
+var statfsTimeout = 5 * time.Second
+
+var statfs = syscall.Statfs
+
+// Mounts with a statfs call still pending from a previous run. The call can't be
+// interrupted, so it isn't repeated before it returned.
+var pendingStatfs = struct {
+	sync.Mutex
+	mounts map[string]bool
+}{mounts: make(map[string]bool)}
+
+type statfsResult struct {
+	index int
+	stat  syscall.Statfs_t
+	err   error
+}
+
+// Mounts are statted concurrently. Those which don't answer within
+// statfsTimeout are returned as unresponsive instead of failing the check.
+func listFilesystems(ctx context.Context, excluded func(mount, fsType string) bool) ([]filesystem, error) {
+	mounted, err := readMounts(excluded)
+	if err != nil {
+		return nil, err
+	}
+	if len(mounted) > 0 && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	statCtx, cancel := context.WithTimeout(ctx, statfsTimeout)
+	defer cancel()
+
+	resultCh := make(chan statfsResult, len(mounted))
+	pending := make(map[int]bool)
+	for i := range mounted {
+		mounted[i].unresponsive = true
+		if statfsAsync(i, mounted[i].mount, resultCh) {
+			pending[i] = true
+		}
+	}
+
+	var failed []int
+	for len(pending) > 0 {
+		select {
+		case result := <-resultCh:
+			delete(pending, result.index)
+			if result.err != nil {
+				failed = append(failed, result.index) // E.g. no permission to access the mount point
+				continue
+			}
+			fs := &mounted[result.index]
+			fs.unresponsive = false
+			fs.blocks, fs.bfree, fs.bavail = result.stat.Blocks, result.stat.Bfree, result.stat.Bavail
+			fs.files, fs.ffree = result.stat.Files, result.stat.Ffree
+		case <-statCtx.Done():
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			clear(pending)
+		}
+	}
+
+	filesystems := make([]filesystem, 0, len(mounted))
+	for i, fs := range mounted {
+		if !slices.Contains(failed, i) {
+			filesystems = append(filesystems, fs)
+		}
+	}
+	return filesystems, nil
+}
+
+// The mounted filesystems which aren't excluded, not statted yet.
+func readMounts(excluded func(mount, fsType string) bool) ([]filesystem, error) {
+	file, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var mounted []filesystem
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		mount := unescapeMount(fields[1])
+		listed := slices.ContainsFunc(mounted, func(fs filesystem) bool { return fs.mount == mount })
+		if !listed && !excluded(mount, fields[2]) {
+			mounted = append(mounted, filesystem{mount: mount, fsType: fields[2]})
+		}
+	}
+
+	return mounted, scanner.Err()
+}
+
+// Stat the mount in the background and send the result to resultCh, which must
+// have room for it. Returns false if a previous call is still pending.
+func statfsAsync(index int, mount string, resultCh chan<- statfsResult) bool {
+	pendingStatfs.Lock()
+	defer pendingStatfs.Unlock()
+	if pendingStatfs.mounts[mount] {
+		return false
+	}
+	pendingStatfs.mounts[mount] = true
+
+	statfs := statfs
+	go func() {
+		result := statfsResult{index: index}
+		result.err = statfs(mount, &result.stat)
+
+		pendingStatfs.Lock()
+		delete(pendingStatfs.mounts, mount)
+		pendingStatfs.Unlock()
+		resultCh <- result
+	}()
+	return true
+}
+
+// Mount points in /proc/self/mounts have spaces etc. escaped as octal, e.g. \040.
+func unescapeMount(mount string) string {
+	var sb strings.Builder
+	for i := 0; i < len(mount); i++ {
+		if mount[i] == '\\' && i+3 < len(mount) {
+			if c, err := strconv.ParseUint(mount[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(mount[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestUnescapeMount(t *testing.T) {
+	if mount := unescapeMount(`/mnt/my\040disk`); mount != "/mnt/my disk" {
+		t.Errorf("expected '/mnt/my disk', got '%s'", mount)
+	}
+}
+
+func TestListFilesystemsContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := listFilesystems(ctx, func(string, string) bool { return false }); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the mounts not to be statted after the context is done, got %v", err)
+	}
+	filesystems, err := listFilesystems(ctx, func(string, string) bool { return true })
+	if err != nil || len(filesystems) > 0 {
+		t.Errorf("expected excluded mounts not to be statted, got %v, %v", filesystems, err)
+	}
+}
+
+func TestListFilesystemsUnresponsive(t *testing.T) {
+	mounted, err := readMounts(func(string, string) bool { return false })
+	if err != nil || len(mounted) < 2 {
+		t.Skip("need at least two mounts:", err)
+	}
+	hung := mounted[0].mount
+
+	unblock := make(chan struct{})
+	calls := make(chan string, 2*len(mounted))
+	defer func(timeout time.Duration) { statfsTimeout = timeout; statfs = syscall.Statfs }(statfsTimeout)
+	statfsTimeout = 100 * time.Millisecond
+	statfs = func(mount string, stat *syscall.Statfs_t) error {
+		calls <- mount
+		if mount == hung {
+			<-unblock
+		}
+		stat.Blocks = 1
+		return nil
+	}
+
+	statHung := func() (unresponsive []string, hungCalls int) {
+		filesystems, err := listFilesystems(context.Background(), func(string, string) bool { return false })
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, fs := range filesystems {
+			if fs.unresponsive {
+				unresponsive = append(unresponsive, fs.mount)
+			}
+		}
+		for len(calls) > 0 {
+			if <-calls == hung {
+				hungCalls++
+			}
+		}
+		return unresponsive, hungCalls
+	}
+
+	unresponsive, hungCalls := statHung()
+	if len(unresponsive) != 1 || unresponsive[0] != hung || hungCalls != 1 {
+		t.Errorf("expected only %s to be unresponsive, got %v", hung, unresponsive)
+	}
+	unresponsive, hungCalls = statHung()
+	if len(unresponsive) != 1 || hungCalls != 0 {
+		t.Errorf("expected %s not to be statted again while pending, got %d calls", hung, hungCalls)
+	}
+
+	close(unblock)
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if unresponsive, _ = statHung(); len(unresponsive) == 0 {
+			return
+		}
+	}
+	t.Errorf("expected %s to be statted again after the pending call returned", hung)
+}
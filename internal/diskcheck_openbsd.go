@@ -0,0 +1,15 @@
+package internal
+
+import "syscall"
+
+func statfsFilesystem(stat syscall.Statfs_t) filesystem {
+	return filesystem{
+		mount:  int8String(stat.F_mntonname[:]),
+		fsType: int8String(stat.F_fstypename[:]),
+		blocks: stat.F_blocks,
+		bfree:  stat.F_bfree,
+		bavail: uint64(max(0, stat.F_bavail)),
+		files:  stat.F_files,
+		ffree:  stat.F_ffree,
+	}
+}
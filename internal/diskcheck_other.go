@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd && !openbsd
+
+package internal
+
+import (
+	"context"
+	"errors"
+)
+
+func listFilesystems(context.Context, func(mount, fsType string) bool) ([]filesystem, error) {
+	return nil, errors.New("disk check not supported on this operating system")
+}
@@ -0,0 +1,19 @@
+//go:build darwin || freebsd
+
+package internal
+
+import "syscall"
+
+// Bavail and Ffree are signed on FreeBSD, negative when the reserved blocks
+// are in use.
+func statfsFilesystem(stat syscall.Statfs_t) filesystem {
+	return filesystem{
+		mount:  int8String(stat.Mntonname[:]),
+		fsType: int8String(stat.Fstypename[:]),
+		blocks: stat.Blocks,
+		bfree:  stat.Bfree,
+		bavail: uint64(max(0, int64(stat.Bavail))),
+		files:  stat.Files,
+		ffree:  uint64(max(0, int64(stat.Ffree))),
+	}
+}
@@ -0,0 +1,68 @@
+package internal
+
+import "testing"
+
+func TestDiskCheck(t *testing.T) {
+	filesystems := []filesystem{
+		{mount: "/", fsType: "ext4", blocks: 1000, bfree: 500, bavail: 500, files: 100, ffree: 90},
+		{mount: "/var", fsType: "ext4", blocks: 1000, bfree: 150, bavail: 100, files: 100, ffree: 5},
+		{mount: "/tmp", fsType: "tmpfs", blocks: 1000, bfree: 0, bavail: 0},
+		{mount: "/proc", fsType: "proc"},
+		{mount: "/mnt/nfs", fsType: "nfs", unresponsive: true},
+	}
+
+	tests := []struct {
+		name     string
+		check    diskCheck
+		expected nagiosCode
+		output   string
+	}{
+		{
+			name:     "space warning",
+			check:    diskCheck{ExcludeTypes: []string{"tmpfs", "nfs"}},
+			expected: nagiosWarning,
+			output:   "DISK WARNING - /var 89.5% used",
+		},
+		{
+			name:     "inodes critical",
+			check:    diskCheck{Mounts: []string{"/", "/var"}, CritInodePercent: 90},
+			expected: nagiosCritical,
+			output:   "95.0% inodes used",
+		},
+		{
+			name:     "not responding",
+			check:    diskCheck{ExcludeTypes: []string{"tmpfs"}},
+			expected: nagiosCritical,
+			output:   "DISK CRITICAL - /mnt/nfs not responding; /var 89.5% used",
+		},
+		{
+			name:     "excluded",
+			check:    diskCheck{Exclude: []string{"/v*", "/tmp", "/mnt/*"}},
+			expected: nagiosOk,
+			output:   "DISK OK - 1 filesystems checked",
+		},
+		{
+			name:     "nothing to check",
+			check:    diskCheck{Mounts: []string{"/home"}},
+			expected: nagiosUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.check.validate(); err != nil {
+				t.Fatal(err)
+			}
+			expectResult(t, tt.check.evaluate(filesystems), tt.expected, tt.output)
+		})
+	}
+
+	result := (&diskCheck{Mounts: []string{"/"}}).evaluate(filesystems)
+	if expected := "/=50%;80;90;0;100 '/ inodes'=10%;;;0;100"; result.perfData.String() != expected {
+		t.Errorf("expected perf data %q, got %q", expected, result.perfData.String())
+	}
+
+	if err := (&diskCheck{Exclude: []string{"["}}).validate(); err == nil {
+		t.Error("expected invalid pattern to be invalid")
+	}
+}
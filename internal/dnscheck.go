@@ -0,0 +1,267 @@
+package internal
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Built-in DNS resolution check, replacing check_dns.
+type dnsCheck struct {
+	Name   string
+	Type   string   `json:"Type,omitempty"`   // A (default), AAAA, MX, TXT or CNAME
+	Server string   `json:"Server,omitempty"` // Resolver as host[:port], default the system's
+	Expect []string `json:"Expect,omitempty"` // Expected records, e.g. "10 mx.foo.zone" for MX
+	WarnMS int      `json:"WarnMS,omitempty"` // Response time thresholds
+	CritMS int      `json:"CritMS,omitempty"`
+}
+
+func (d *dnsCheck) validate() error {
+	if d.Name == "" {
+		return errors.New("DNS check without Name")
+	}
+	switch strings.ToUpper(d.Type) {
+	case "", "A", "AAAA", "MX", "TXT", "CNAME":
+	default:
+		return fmt.Errorf("unsupported DNS record type '%s'", d.Type)
+	}
+	return nil
+}
+
+func (d *dnsCheck) run(ctx context.Context) checkResult {
+	recordType := strings.ToUpper(d.Type)
+	if recordType == "" {
+		recordType = "A"
+	}
+
+	start := time.Now()
+	records, err := d.lookup(ctx, recordType)
+	elapsed := time.Since(start)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return resultf(nagiosCritical, "DNS CRITICAL: %s %s: NXDOMAIN", d.Name, recordType)
+		}
+		return resultf(nagiosCritical, "DNS CRITICAL: %v", err)
+	}
+
+	status := thresholdStatus(float64(elapsed.Milliseconds()), float64(d.WarnMS), float64(d.CritMS))
+	output := fmt.Sprintf("%s %s returns %s", d.Name, recordType, strings.Join(records, ", "))
+
+	if len(d.Expect) > 0 {
+		expected := make([]string, len(d.Expect))
+		for i, record := range d.Expect {
+			expected[i] = normalizeRecord(recordType, record)
+		}
+		slices.Sort(expected)
+		if !slices.Equal(records, expected) {
+			status = nagiosCritical
+			output += fmt.Sprintf(", expected %s", strings.Join(expected, ", "))
+		}
+	}
+
+	zero := 0.0
+	return checkResult{
+		status: status,
+		output: fmt.Sprintf("DNS %s: %s in %.3f seconds", status.Str(), output, elapsed.Seconds()),
+		perfData: perfData{{
+			Label: "time",
+			Value: elapsed.Seconds(),
+			UOM:   "s",
+			Warn:  formatThreshold(float64(d.WarnMS) / 1000),
+			Crit:  formatThreshold(float64(d.CritMS) / 1000),
+			Min:   &zero,
+		}},
+	}
+}
+
+// Look up the records as sorted and normalized strings.
+func (d *dnsCheck) lookup(ctx context.Context, recordType string) ([]string, error) {
+	if d.Server != "" {
+		return d.query(ctx, recordType)
+	}
+
+	var (
+		records  []string
+		resolver = net.DefaultResolver
+	)
+	switch recordType {
+	case "A", "AAAA":
+		network := "ip4"
+		if recordType == "AAAA" {
+			network = "ip6"
+		}
+		ips, err := resolver.LookupIP(ctx, network, d.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			records = append(records, ip.String())
+		}
+	case "MX":
+		mxs, err := resolver.LookupMX(ctx, d.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			records = append(records, normalizeRecord(recordType, fmt.Sprintf("%d %s", mx.Pref, mx.Host)))
+		}
+	case "TXT":
+		txts, err := resolver.LookupTXT(ctx, d.Name)
+		if err != nil {
+			return nil, err
+		}
+		records = txts
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, d.Name)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, normalizeRecord(recordType, cname))
+	}
+
+	slices.Sort(records)
+	return records, nil
+}
+
+var dnsTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"MX":    dnsmessage.TypeMX,
+	"TXT":   dnsmessage.TypeTXT,
+	"CNAME": dnsmessage.TypeCNAME,
+}
+
+// Query the Server directly, unlike the system's resolver, which would answer
+// from the hosts file first. A truncated UDP response is retried over TCP.
+func (d *dnsCheck) query(ctx context.Context, recordType string) ([]string, error) {
+	server := d.Server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	name, err := dnsmessage.NewName(strings.TrimSuffix(d.Name, ".") + ".")
+	if err != nil {
+		return nil, err
+	}
+	qtype := dnsTypes[recordType]
+
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: uint16(rand.Uint32()), RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	resp, err := exchangeDNS(ctx, "udp", server, query)
+	if err == nil && resp.Truncated {
+		resp, err = exchangeDNS(ctx, "tcp", server, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, &net.DNSError{Err: "no such host", Name: d.Name, Server: server, IsNotFound: true}
+	default:
+		return nil, &net.DNSError{Err: "server returned " + strings.TrimPrefix(resp.RCode.String(), "RCode"),
+			Name: d.Name, Server: server}
+	}
+
+	var records []string
+	for _, answer := range resp.Answers {
+		if answer.Header.Type != qtype {
+			continue // E.g. the CNAME records an A record is resolved through
+		}
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			records = append(records, net.IP(body.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			records = append(records, net.IP(body.AAAA[:]).String())
+		case *dnsmessage.MXResource:
+			records = append(records, normalizeRecord(recordType, fmt.Sprintf("%d %s", body.Pref, body.MX)))
+		case *dnsmessage.TXTResource:
+			records = append(records, strings.Join(body.TXT, ""))
+		case *dnsmessage.CNAMEResource:
+			records = append(records, normalizeRecord(recordType, body.CNAME.String()))
+		}
+	}
+	if len(records) == 0 {
+		return nil, &net.DNSError{Err: fmt.Sprintf("no %s records", recordType), Name: d.Name, Server: server}
+	}
+
+	slices.Sort(records)
+	return records, nil
+}
+
+// Send the query and wait for the response, within the check timeout.
+func exchangeDNS(ctx context.Context, network, server string, query dnsmessage.Message) (dnsmessage.Message, error) {
+	var resp dnsmessage.Message
+
+	packed, err := query.Pack()
+	if err != nil {
+		return resp, err
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, server)
+	if err != nil {
+		return resp, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	buf := make([]byte, 65535)
+	if network == "tcp" {
+		// Messages over TCP are prefixed with their length.
+		packed = append(binary.BigEndian.AppendUint16(nil, uint16(len(packed))), packed...)
+		if _, err := conn.Write(packed); err != nil {
+			return resp, err
+		}
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return resp, err
+		}
+		buf = buf[:binary.BigEndian.Uint16(buf)]
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return resp, err
+		}
+	} else {
+		if _, err := conn.Write(packed); err != nil {
+			return resp, err
+		}
+		n, err := conn.Read(buf)
+		if err != nil {
+			return resp, err
+		}
+		buf = buf[:n]
+	}
+
+	if err := resp.Unpack(buf); err != nil {
+		return resp, err
+	}
+	if !resp.Response || resp.ID != query.ID {
+		return resp, fmt.Errorf("unexpected DNS response from %s", server)
+	}
+	return resp, nil
+}
+
+// Host names compare case insensitive and with or without the trailing dot,
+// while TXT records compare as they are.
+func normalizeRecord(recordType, record string) string {
+	switch {
+	case recordType == "TXT":
+		return record
+	case net.ParseIP(record) != nil:
+		return net.ParseIP(record).String()
+	default:
+		return strings.TrimSuffix(strings.ToLower(record), ".")
+	}
+}
@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+)
+
+// Serve a few fixed records over UDP, anything else is NXDOMAIN.
+func serveDNS(t *testing.T) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	encodeName := func(name string) (encoded []byte) {
+		for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+			encoded = append(encoded, byte(len(label)))
+			encoded = append(encoded, label...)
+		}
+		return append(encoded, 0)
+	}
+	type question struct {
+		name  string
+		qtype uint16
+	}
+	records := map[question][][]byte{
+		{"www.example.org.", 1}:  {{192, 0, 2, 1}, {192, 0, 2, 2}},
+		{"www.example.org.", 28}: {},
+		{"example.org.", 15}:     {append([]byte{0, 10}, encodeName("mx.example.org.")...)},
+		{"example.org.", 16}:     {append([]byte{23}, "v=spf1 MX -all Example."...)},
+	}
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query := buf[:n]
+
+			// Walk the labels of the question name.
+			var name string
+			i := 12
+			for i < len(query) && query[i] != 0 {
+				name += string(query[i+1:i+1+int(query[i])]) + "."
+				i += 1 + int(query[i])
+			}
+			qtype := binary.BigEndian.Uint16(query[i+1:])
+
+			answers, ok := records[question{name, qtype}]
+			resp := append([]byte{}, query[:2]...)
+			if ok {
+				resp = append(resp, 0x81, 0x80)
+			} else {
+				resp = append(resp, 0x81, 0x83) // NXDOMAIN
+			}
+			resp = binary.BigEndian.AppendUint16(resp, 1)
+			resp = binary.BigEndian.AppendUint16(resp, uint16(len(answers)))
+			resp = append(resp, 0, 0, 0, 0)
+			resp = append(resp, query[12:i+5]...)
+			for _, rdata := range answers {
+				resp = append(resp, 0xc0, 12)
+				resp = binary.BigEndian.AppendUint16(resp, qtype)
+				resp = append(resp, 0, 1, 0, 0, 0, 60)
+				resp = binary.BigEndian.AppendUint16(resp, uint16(len(rdata)))
+				resp = append(resp, rdata...)
+			}
+			conn.WriteTo(resp, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestDNSCheck(t *testing.T) {
+	server := serveDNS(t)
+
+	tests := []checkTest{
+		{
+			name:     "A records",
+			check:    check{DNS: &dnsCheck{Name: "www.example.org.", Server: server}},
+			expected: nagiosOk,
+			output:   "returns 192.0.2.1, 192.0.2.2",
+		},
+		{
+			name:     "A records as expected",
+			check:    check{DNS: &dnsCheck{Name: "www.example.org.", Server: server, Expect: []string{"192.0.2.2", "192.0.2.1"}}},
+			expected: nagiosOk,
+		},
+		{
+			name:     "A records not as expected",
+			check:    check{DNS: &dnsCheck{Name: "www.example.org.", Server: server, Expect: []string{"192.0.2.1"}}},
+			expected: nagiosCritical,
+			output:   "expected 192.0.2.1",
+		},
+		{
+			name:     "MX record",
+			check:    check{DNS: &dnsCheck{Name: "example.org.", Type: "mx", Server: server, Expect: []string{"10 MX.example.org."}}},
+			expected: nagiosOk,
+			output:   "returns 10 mx.example.org",
+		},
+		{
+			name:     "TXT record compared as is",
+			check:    check{DNS: &dnsCheck{Name: "example.org.", Type: "TXT", Server: server, Expect: []string{"v=spf1 MX -all Example."}}},
+			expected: nagiosOk,
+			output:   "returns v=spf1 MX -all Example.",
+		},
+		{
+			name:     "TXT record not as expected",
+			check:    check{DNS: &dnsCheck{Name: "example.org.", Type: "TXT", Server: server, Expect: []string{"v=spf1 mx -all example"}}},
+			expected: nagiosCritical,
+			output:   "expected v=spf1 mx -all example",
+		},
+		{
+			name:     "NXDOMAIN",
+			check:    check{DNS: &dnsCheck{Name: "nope.example.org.", Server: server}},
+			expected: nagiosCritical,
+			output:   "NXDOMAIN",
+		},
+		{
+			name:     "hosts file not used",
+			check:    check{DNS: &dnsCheck{Name: "localhost", Server: server}},
+			expected: nagiosCritical,
+			output:   "NXDOMAIN",
+		},
+		{
+			name:     "no records of the type",
+			check:    check{DNS: &dnsCheck{Name: "www.example.org.", Type: "AAAA", Server: server}},
+			expected: nagiosCritical,
+			output:   "no AAAA records",
+		},
+	}
+
+	runCheckTests(t, tests)
+
+	if err := (&dnsCheck{Name: "example.org", Type: "SRV"}).validate(); err == nil {
+		t.Error("expected unsupported record type to be invalid")
+	}
+}
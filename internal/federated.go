@@ -9,51 +9,75 @@ import (
 	"time"
 )
 
+// The state returned by a federated endpoint, or why it couldn't be fetched.
+type federatedState struct {
+	endpoint string
+	epoch    int64
+	bytes    []byte
+	err      error
+}
+
 // Query all federated endpoints and merge states
 func mergeFederated(ctx context.Context, state state, conf config) state {
-	critical := func(cs checkResult, err error) {
-		cs.output = err.Error()
-		cs.status = nagiosCritical
-		state.update(cs)
-	}
+	return state.mergeFetched(fetchFederated(ctx, conf))
+}
+
+// Query all federated endpoints. This doesn't touch the state, so the daemon
+// doesn't need to hold its lock meanwhile.
+func fetchFederated(ctx context.Context, conf config) []federatedState {
+	fetched := make([]federatedState, 0, len(conf.Federated))
 
 	for _, endpoint := range conf.Federated {
 		log.Println("Querying federated endpoint", endpoint)
-		cs := checkResult{
-			name:      fmt.Sprintf("Federated endpoint %s", endpoint),
-			epoch:     time.Now().Unix(),
-			federated: true,
-		}
+		fs := federatedState{endpoint: endpoint, epoch: time.Now().Unix()}
+		fs.bytes, fs.err = fetchEndpoint(ctx, endpoint)
+		fetched = append(fetched, fs)
+	}
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
-		if err != nil {
-			critical(cs, err)
-			continue
-		}
+	return fetched
+}
 
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			critical(cs, err)
-			continue
-		}
+func fetchEndpoint(ctx context.Context, endpoint string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
 
-		bytes, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			critical(cs, err)
-			continue
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+// Merge the fetched states. Every endpoint is reported as a check of its own,
+// which is CRITICAL if its state couldn't be fetched or merged.
+func (s state) mergeFetched(fetched []federatedState) state {
+	for _, fs := range fetched {
+		cs := checkResult{
+			name:      fmt.Sprintf("Federated endpoint %s", fs.endpoint),
+			epoch:     fs.epoch,
+			federated: fs.endpoint,
 		}
 
-		if err := state.mergeFromBytes(bytes); err != nil {
-			critical(cs, err)
+		err := fs.err
+		if err == nil {
+			err = s.mergeFromBytes(fs.bytes, fs.endpoint, cs.name)
+		}
+		if err != nil {
+			cs.output = err.Error()
+			cs.status = nagiosCritical
+			s.update(cs)
 			continue
 		}
 
-		cs.output = fmt.Sprintf("OK: Federated endpoint returned %d bytes", len(bytes))
+		cs.output = fmt.Sprintf("OK: Federated endpoint returned %d bytes", len(fs.bytes))
 		cs.status = nagiosOk
-		state.update(cs)
+		s.update(cs)
 	}
 
-	log.Println(state)
-	return state
+	log.Println(s)
+	return s
 }
@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Built-in file freshness, size and integrity check.
+type fileCheck struct {
+	Path      string
+	WarnAgeH  float64 `json:"WarnAgeH,omitempty"` // Modification time thresholds in hours
+	CritAgeH  float64 `json:"CritAgeH,omitempty"`
+	MinSize   int64   `json:"MinSize,omitempty"`  // CRITICAL if smaller, in bytes
+	Checksum  bool    `json:"Checksum,omitempty"` // CRITICAL if changed from the baseline
+	stateFile string
+}
+
+// The checksum of the file when it was first checked.
+type fileBaseline struct {
+	SHA256 string
+}
+
+func (f *fileCheck) setStateFile(file string) {
+	f.stateFile = file
+}
+
+func (f *fileCheck) validate() error {
+	if f.Path == "" {
+		return errors.New("file check without Path")
+	}
+	return nil
+}
+
+// The file may be on an unresponsive network filesystem, so the blocking
+// calls give up when the context is done.
+func (f *fileCheck) run(ctx context.Context) checkResult {
+	info, err := withContext(ctx, func() (os.FileInfo, error) { return os.Stat(f.Path) })
+	if errors.Is(err, os.ErrNotExist) {
+		return resultf(nagiosCritical, "FILE CRITICAL - %s is missing", f.Path)
+	}
+	if err != nil {
+		return resultf(nagiosUnknown, "FILE UNKNOWN: %v", err)
+	}
+	if info.IsDir() {
+		return resultf(nagiosUnknown, "FILE UNKNOWN: %s is a directory", f.Path)
+	}
+
+	age := time.Since(info.ModTime())
+	status := thresholdStatus(age.Hours(), f.WarnAgeH, f.CritAgeH)
+	var problems []string
+	if status != nagiosOk {
+		problems = append(problems, fmt.Sprintf("modified %.1f hours ago", age.Hours()))
+	}
+
+	if info.Size() < f.MinSize {
+		status = nagiosCritical
+		problems = append(problems, fmt.Sprintf("%d bytes is smaller than %d bytes", info.Size(), f.MinSize))
+	}
+
+	if f.Checksum {
+		changed, err := f.checksumChanged(ctx)
+		if err != nil {
+			return resultf(nagiosUnknown, "FILE UNKNOWN: %v", err)
+		}
+		if changed {
+			status = nagiosCritical
+			problems = append(problems, "checksum changed")
+		}
+	}
+
+	output := fmt.Sprintf("FILE %s - %s is %.1f hours old, %d bytes", status.Str(),
+		f.Path, age.Hours(), info.Size())
+	if len(problems) > 0 {
+		output = fmt.Sprintf("FILE %s - %s: %s", status.Str(), f.Path, strings.Join(problems, ", "))
+	}
+
+	zero := 0.0
+	return checkResult{
+		status: status,
+		output: output,
+		perfData: perfData{
+			{
+				Label: "age",
+				Value: float64(int64(age.Seconds())),
+				UOM:   "s",
+				Warn:  formatThreshold(f.WarnAgeH * 3600),
+				Crit:  formatThreshold(f.CritAgeH * 3600),
+				Min:   &zero,
+			},
+			{Label: "size", Value: float64(info.Size()), UOM: "B", Min: &zero},
+		},
+	}
+}
+
+// Compare the checksum with the baseline, which is taken on the first run.
+func (f *fileCheck) checksumChanged(ctx context.Context) (bool, error) {
+	checksum, err := withContext(ctx, f.checksum)
+	if err != nil {
+		return false, err
+	}
+
+	var baseline fileBaseline
+	switch err := readCheckState(f.stateFile, &baseline); {
+	case errors.Is(err, os.ErrNotExist):
+		return false, writeCheckState(f.stateFile, fileBaseline{SHA256: checksum})
+	case err != nil:
+		return false, err
+	}
+
+	return baseline.SHA256 != checksum, nil
+}
+
+func (f *fileCheck) checksum() (string, error) {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
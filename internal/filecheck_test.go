@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCheck(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "backup.done")
+	if err := os.WriteFile(marker, []byte("done\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	yesterday := time.Now().Add(-25 * time.Hour)
+	if err := os.Chtimes(marker, yesterday, yesterday); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []checkTest{
+		{
+			name:     "fresh enough",
+			check:    check{File: &fileCheck{Path: marker, WarnAgeH: 26, CritAgeH: 48}},
+			expected: nagiosOk,
+			output:   "is 25.0 hours old, 5 bytes",
+		},
+		{
+			name:     "too old",
+			check:    check{File: &fileCheck{Path: marker, WarnAgeH: 12, CritAgeH: 24}},
+			expected: nagiosCritical,
+			output:   "modified 25.0 hours ago",
+		},
+		{
+			name:     "too small",
+			check:    check{File: &fileCheck{Path: marker, MinSize: 1024}},
+			expected: nagiosCritical,
+			output:   "5 bytes is smaller than 1024 bytes",
+		},
+		{
+			name:     "missing",
+			check:    check{File: &fileCheck{Path: filepath.Join(dir, "nope")}},
+			expected: nagiosCritical,
+			output:   "is missing",
+		},
+	}
+
+	runCheckTests(t, tests)
+}
+
+func TestFileCheckChecksum(t *testing.T) {
+	dir := t.TempDir()
+	passwd := filepath.Join(dir, "passwd")
+	if err := os.WriteFile(passwd, []byte("root:x:0:0::/root:/bin/sh\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fileCheck := fileCheck{Path: passwd, Checksum: true}
+	fileCheck.setStateFile(checkStateFile(dir, "File passwd"))
+
+	for i, change := range []string{"", "", "evil:x:0:0::/:/bin/sh\n", ""} {
+		if change != "" {
+			if err := os.WriteFile(passwd, []byte(change), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		result := check{File: &fileCheck}.run(context.Background(), "File passwd")
+		expected := nagiosOk
+		if i >= 2 {
+			// Stays CRITICAL until the baseline is removed.
+			expected = nagiosCritical
+		}
+		if result.status != expected {
+			t.Errorf("run %d: expected %s, got %s: %s", i, expected.Str(), result.status.Str(), result.output)
+		}
+	}
+}
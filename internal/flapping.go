@@ -0,0 +1,67 @@
+package internal
+
+import "slices"
+
+// Number of most recent check results used for flap detection, same as Nagios.
+const flapHistorySize = 21
+
+// Percentage of state changes within the history, where recent changes are
+// weighted more than old ones (from 0.8 for the oldest to 1.2 for the newest).
+func percentStateChange(history []nagiosCode) float64 {
+	numTransitions := len(history) - 1
+	if numTransitions < 1 {
+		return 0
+	}
+
+	var total float64
+	for i := 0; i < numTransitions; i++ {
+		if history[i] == history[i+1] {
+			continue
+		}
+		weight := 1.0
+		if numTransitions > 1 {
+			weight = 0.8 + 0.4*float64(i)/float64(numTransitions-1)
+		}
+		total += weight
+	}
+
+	return total / float64(numTransitions) * 100
+}
+
+// Append the current status to the history and update the flapping state.
+// Flapping starts when the percent state change reaches the high threshold and
+// stops only after it dropped below the low threshold again.
+func (cs *checkState) updateFlapping(prevState checkState, low, high float64) {
+	if high <= 0 {
+		return // Flap detection disabled
+	}
+	if low <= 0 || low > high {
+		low = high
+	}
+
+	cs.History = append(cs.History, prevState.History...)
+	if len(cs.History) == 0 {
+		// Like Nagios, start with a full history of the initial status, so
+		// that the first state changes don't count as flapping.
+		initial := cs.Status
+		if prevState.Epoch != 0 {
+			initial = prevState.Status
+		}
+		cs.History = slices.Repeat([]nagiosCode{initial}, flapHistorySize-1)
+	}
+	cs.History = append(cs.History, cs.Status)
+	if len(cs.History) > flapHistorySize {
+		cs.History = cs.History[len(cs.History)-flapHistorySize:]
+	}
+
+	cs.PercentStateChange = percentStateChange(cs.History)
+	switch {
+	case !prevState.Flapping && cs.PercentStateChange >= high:
+		cs.Flapping = true
+	case prevState.Flapping && cs.PercentStateChange < low:
+		cs.Flapping = false
+	default:
+		cs.Flapping = prevState.Flapping
+	}
+	cs.flapChanged = cs.Flapping != prevState.Flapping
+}
@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestPercentStateChange(t *testing.T) {
+	if pct := percentStateChange([]nagiosCode{nagiosOk, nagiosOk, nagiosOk}); pct != 0 {
+		t.Errorf("expected 0%% state change, got %v", pct)
+	}
+
+	history := []nagiosCode{nagiosOk, nagiosCritical, nagiosOk, nagiosCritical, nagiosOk}
+	if pct := percentStateChange(history); pct != 100 {
+		t.Errorf("expected 100%% state change, got %v", pct)
+	}
+
+	// A recent change weighs more than an old one.
+	oldChange := percentStateChange([]nagiosCode{nagiosOk, nagiosCritical, nagiosCritical})
+	newChange := percentStateChange([]nagiosCode{nagiosOk, nagiosOk, nagiosCritical})
+	if oldChange >= newChange {
+		t.Errorf("expected %v < %v", oldChange, newChange)
+	}
+}
+
+func TestFlapping(t *testing.T) {
+	s := state{checks: make(map[string]checkState)}
+	result := checkResult{name: "Check Foo", flapLow: 20, flapHigh: 40}
+
+	// A single outage isn't flapping.
+	for _, status := range []nagiosCode{nagiosOk, nagiosCritical} {
+		result.status = status
+		s.update(result)
+	}
+	if cs := s.checks["Check Foo"]; cs.Flapping || !cs.changed() {
+		t.Errorf("expected the first outage to be a change, got %+v", cs)
+	}
+
+	results := 2
+	for ; results <= flapHistorySize && !s.checks["Check Foo"].Flapping; results++ {
+		result.status = []nagiosCode{nagiosOk, nagiosCritical}[results%2]
+		s.update(result)
+	}
+	cs := s.checks["Check Foo"]
+	if !cs.Flapping || !cs.flapChanged {
+		t.Fatalf("expected check to start flapping, got %+v", cs)
+	}
+	if results < 8 {
+		t.Errorf("expected check to start flapping only after several changes, got %d results", results)
+	}
+	result.status = nagiosOk
+	if cs.Status == nagiosOk {
+		result.status = nagiosCritical
+	}
+	s.update(result)
+	if cs := s.checks["Check Foo"]; cs.changed() {
+		t.Errorf("expected changes of a flapping check to be suppressed, got %+v", cs)
+	}
+
+	result.status = nagiosOk
+	for i := 0; i < flapHistorySize && s.checks["Check Foo"].Flapping; i++ {
+		s.update(result)
+	}
+	cs = s.checks["Check Foo"]
+	if cs.Flapping || !cs.flapChanged {
+		t.Errorf("expected check to stop flapping, got %+v", cs)
+	}
+	if cs.PercentStateChange >= 20 {
+		t.Errorf("expected percent state change below the low threshold, got %v", cs.PercentStateChange)
+	}
+}
@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Render the check dependency graph of the config file in the given format
+// ("dot" or "text"). With withStatus, the current status from the state is
+// shown as well.
+func Graph(w io.Writer, configFile, format string, withStatus bool) error {
+	conf, err := newConfig(configFile)
+	if err != nil {
+		return err
+	}
+
+	var s state
+	if withStatus {
+		if s, err = newState(conf); err != nil {
+			return err
+		}
+	}
+
+	g := newDepGraph(conf, s)
+	switch format {
+	case "dot":
+		g.renderDot(w)
+	case "text":
+		g.renderText(w)
+	default:
+		return fmt.Errorf("unknown graph format '%s'", format)
+	}
+	return nil
+}
+
+type depGraph struct {
+	names      []string            // Sorted, including external (e.g. federated) checks
+	dependents map[string][]string // Edges from a check to the checks depending on it
+	external   map[string]bool     // Not configured locally
+	checks     map[string]checkState
+}
+
+func newDepGraph(conf config, s state) depGraph {
+	g := depGraph{
+		names:      conf.checkNames(),
+		dependents: make(map[string][]string),
+		external:   make(map[string]bool),
+		checks:     s.checks,
+	}
+
+	for _, name := range conf.checkNames() {
+		for _, depName := range conf.Checks[name].DependsOn {
+			if _, ok := conf.Checks[depName]; !ok && !g.external[depName] {
+				g.external[depName] = true
+				g.names = append(g.names, depName)
+			}
+			g.dependents[depName] = append(g.dependents[depName], name)
+		}
+	}
+	slices.Sort(g.names)
+
+	return g
+}
+
+func (g depGraph) status(name string) (nagiosCode, bool) {
+	cs, ok := g.checks[name]
+	return cs.Status, ok
+}
+
+func (g depGraph) renderDot(w io.Writer) {
+	colors := map[nagiosCode]string{
+		nagiosOk:          "palegreen",
+		nagiosWarning:     "yellow",
+		nagiosCritical:    "tomato",
+		nagiosUnknown:     "orange",
+		nagiosUnreachable: "lightgray",
+	}
+	// Backslashes are escapes in DOT labels too, e.g. \N for the node name.
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	quote := func(str string) string {
+		return `"` + escaper.Replace(str) + `"`
+	}
+
+	fmt.Fprintln(w, "digraph gogios {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [shape=box];")
+
+	for _, name := range g.names {
+		var attrs []string
+		if status, ok := g.status(name); ok {
+			attrs = append(attrs, "style=filled", "fillcolor="+colors[status],
+				"tooltip="+quote(status.Str()))
+		}
+		if g.external[name] {
+			attrs = append(attrs, "peripheries=2")
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(w, "  %s [%s];\n", quote(name), strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(w, "  %s;\n", quote(name))
+		}
+	}
+
+	for _, name := range g.names {
+		for _, dependent := range g.dependents[name] {
+			fmt.Fprintf(w, "  %s -> %s;\n", quote(name), quote(dependent))
+		}
+	}
+
+	fmt.Fprintln(w, "}")
+}
+
+// Print every check without dependencies with all its dependents indented
+// below. Checks with several dependencies show up several times.
+func (g depGraph) renderText(w io.Writer) {
+	hasDeps := make(map[string]bool)
+	for _, dependents := range g.dependents {
+		for _, dependent := range dependents {
+			hasDeps[dependent] = true
+		}
+	}
+
+	printed := make(map[string]bool)
+	var printTree func(name string, depth int, path []string)
+	printTree = func(name string, depth int, path []string) {
+		printed[name] = true
+		fmt.Fprintf(w, "%s%s", strings.Repeat("  ", depth), name)
+		if status, ok := g.status(name); ok {
+			fmt.Fprintf(w, " [%s]", status.Str())
+		}
+		if g.external[name] {
+			fmt.Fprint(w, " (external)")
+		}
+
+		if slices.Contains(path, name) {
+			fmt.Fprintln(w, " (cycle)")
+			return
+		}
+		fmt.Fprintln(w)
+
+		path = append(path, name)
+		for _, dependent := range g.dependents[name] {
+			printTree(dependent, depth+1, path)
+		}
+	}
+
+	for _, name := range g.names {
+		if !hasDeps[name] {
+			printTree(name, 0, nil)
+		}
+	}
+
+	// Checks only reachable through a cycle
+	for _, name := range g.names {
+		if !printed[name] {
+			printTree(name, 0, nil)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderGraph(t *testing.T) {
+	conf := config{Checks: map[string]check{
+		"Ping":                 {},
+		"DNS":                  {DependsOn: []string{"Ping"}},
+		"HTTP":                 {DependsOn: []string{"DNS", "Federated Check"}},
+		`Share \\fs1\"backup"`: {DependsOn: []string{"Ping"}},
+	}}
+	s := state{checks: map[string]checkState{
+		"Ping": {Status: nagiosOk},
+		"HTTP": {Status: nagiosCritical},
+	}}
+	g := newDepGraph(conf, s)
+
+	var sb strings.Builder
+	g.renderText(&sb)
+	expected := `Federated Check (external)
+  HTTP [CRITICAL]
+Ping [OK]
+  DNS
+    HTTP [CRITICAL]
+  Share \\fs1\"backup"
+`
+	if sb.String() != expected {
+		t.Errorf("expected text graph\n%s\ngot\n%s", expected, sb.String())
+	}
+
+	sb.Reset()
+	g.renderDot(&sb)
+	for _, expected := range []string{
+		`"Ping" [style=filled, fillcolor=palegreen, tooltip="OK"];`,
+		`"Ping" -> "DNS";`,
+		`"DNS" -> "HTTP";`,
+		`"Federated Check" -> "HTTP";`,
+		`"Ping" -> "Share \\\\fs1\\\"backup\"";`,
+	} {
+		if !strings.Contains(sb.String(), expected) {
+			t.Errorf("expected dot graph to contain %q, got\n%s", expected, sb.String())
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Passive check (a dead man's switch) which is CRITICAL unless pinged at least
+// every PeriodS seconds (plus GraceS), e.g. by a cron job via gogios -ping.
+type heartbeatCheck struct {
+	PeriodS   int
+	GraceS    int `json:"GraceS,omitempty"`
+	stateFile string
+}
+
+// The last ping received.
+type heartbeat struct {
+	Epoch int64
+}
+
+func (h *heartbeatCheck) setStateFile(file string) {
+	h.stateFile = file
+}
+
+func (h *heartbeatCheck) validate() error {
+	if h.PeriodS <= 0 {
+		return errors.New("heartbeat check without PeriodS")
+	}
+	if h.GraceS < 0 {
+		return errors.New("heartbeat check with negative GraceS")
+	}
+	return nil
+}
+
+func (h *heartbeatCheck) ping() error {
+	return writeCheckState(h.stateFile, heartbeat{Epoch: time.Now().Unix()})
+}
+
+func (h *heartbeatCheck) run(context.Context) checkResult {
+	var last heartbeat
+	switch err := readCheckState(h.stateFile, &last); {
+	case errors.Is(err, os.ErrNotExist):
+		return resultf(nagiosCritical, "HEARTBEAT CRITICAL - No heartbeat received yet")
+	case err != nil:
+		return resultf(nagiosUnknown, "HEARTBEAT UNKNOWN: %v", err)
+	}
+
+	age := time.Since(time.Unix(last.Epoch, 0)).Truncate(time.Second)
+	overdue := time.Duration(h.PeriodS+h.GraceS) * time.Second
+
+	status := nagiosOk
+	output := fmt.Sprintf("HEARTBEAT OK - Last heartbeat %v ago", age)
+	if age > overdue {
+		status = nagiosCritical
+		output = fmt.Sprintf("HEARTBEAT CRITICAL - Last heartbeat %v ago, expected every %v",
+			age, time.Duration(h.PeriodS)*time.Second)
+	}
+
+	zero := 0.0
+	return checkResult{
+		status: status,
+		output: output,
+		perfData: perfData{{
+			Label: "age",
+			Value: age.Seconds(),
+			UOM:   "s",
+			Crit:  strconv.Itoa(h.PeriodS + h.GraceS),
+			Min:   &zero,
+		}},
+	}
+}
+
+// Record a heartbeat of the named check, as received by gogios -ping.
+func Ping(configFile, name string) error {
+	conf, err := newConfig(configFile)
+	if err != nil {
+		return err
+	}
+	heartbeat, err := conf.heartbeat(name)
+	if err != nil {
+		return err
+	}
+	return heartbeat.ping()
+}
+
+func (conf config) heartbeat(name string) (*heartbeatCheck, error) {
+	check, ok := conf.Checks[name]
+	if !ok {
+		return nil, fmt.Errorf("no such check '%s'", name)
+	}
+	if check.Heartbeat == nil {
+		return nil, fmt.Errorf("check '%s' is not a heartbeat check", name)
+	}
+	return check.Heartbeat, nil
+}
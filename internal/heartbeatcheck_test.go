@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatCheck(t *testing.T) {
+	heartbeatCheck := heartbeatCheck{PeriodS: 3600, GraceS: 600}
+	heartbeatCheck.setStateFile(checkStateFile(t.TempDir(), "Backup"))
+
+	steps := []struct {
+		name     string
+		prepare  func() error
+		expected nagiosCode
+		output   string
+	}{
+		{
+			name:     "never pinged",
+			prepare:  func() error { return nil },
+			expected: nagiosCritical,
+			output:   "No heartbeat received yet",
+		},
+		{
+			name:     "pinged",
+			prepare:  heartbeatCheck.ping,
+			expected: nagiosOk,
+			output:   "Last heartbeat 0s ago",
+		},
+		{
+			name: "within grace",
+			prepare: func() error {
+				return writeCheckState(heartbeatCheck.stateFile,
+					heartbeat{Epoch: time.Now().Add(-65 * time.Minute).Unix()})
+			},
+			expected: nagiosOk,
+		},
+		{
+			name: "overdue",
+			prepare: func() error {
+				return writeCheckState(heartbeatCheck.stateFile,
+					heartbeat{Epoch: time.Now().Add(-2 * time.Hour).Unix()})
+			},
+			expected: nagiosCritical,
+			output:   "Last heartbeat 2h0m0s ago, expected every 1h0m0s",
+		},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if err := step.prepare(); err != nil {
+				t.Fatal(err)
+			}
+			result := check{Heartbeat: &heartbeatCheck}.run(context.Background(), "Backup")
+			expectResult(t, result, step.expected, step.output)
+		})
+	}
+}
+
+func TestPingEndpoint(t *testing.T) {
+	dir := t.TempDir()
+	heartbeatCheck := &heartbeatCheck{PeriodS: 60}
+	heartbeatCheck.setStateFile(checkStateFile(dir, "Backup db1"))
+
+	conf := config{
+		ListenToken: "secret",
+		Checks: map[string]check{
+			"Backup db1": {Heartbeat: heartbeatCheck},
+			"Ping db1":   {Plugin: "/usr/lib/nagios/plugins/check_ping"},
+		},
+	}
+	server := httptest.NewServer(conf.httpHandler())
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		token    string
+		expected int
+	}{
+		{name: "no token", path: "/ping/Backup%20db1", expected: http.StatusUnauthorized},
+		{name: "wrong token", path: "/ping/Backup%20db1", token: "guess", expected: http.StatusUnauthorized},
+		{name: "unknown check", path: "/ping/Backup%20db2", token: "secret", expected: http.StatusNotFound},
+		{name: "not a heartbeat", path: "/ping/Ping%20db1", token: "secret", expected: http.StatusNotFound},
+		{name: "ping", path: "/ping/Backup%20db1", token: "secret", expected: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, server.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, resp.StatusCode)
+			}
+		})
+	}
+
+	if result := heartbeatCheck.run(context.Background()); result.status != nagiosOk {
+		t.Errorf("expected heartbeat to be OK after the ping, got %s: %s", result.status.Str(), result.output)
+	}
+}
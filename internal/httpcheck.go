@@ -0,0 +1,247 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Redirect policies of the HTTP check
+const (
+	httpRedirectFollow = "follow" // Follow up to 10 redirects (default)
+	httpRedirectNone   = "none"   // Don't follow, the redirect is the response
+)
+
+// Only so much of the response body is read and matched.
+const httpMaxBodySize = 10 << 20
+
+// Built-in HTTP(S) check, replacing check_http.
+type httpCheck struct {
+	URL            string
+	Method         string            `json:"Method,omitempty"` // Default GET
+	Headers        map[string]string `json:"Headers,omitempty"`
+	Body           string            `json:"Body,omitempty"`           // Request body
+	ExpectStatus   []int             `json:"ExpectStatus,omitempty"`   // Default any 2xx or 3xx
+	BodyContains   string            `json:"BodyContains,omitempty"`   // Expected substring
+	BodyRegex      string            `json:"BodyRegex,omitempty"`      // Expected regex match
+	JSONPath       map[string]string `json:"JSONPath,omitempty"`       // E.g. "status.code": "200"
+	Redirects      string            `json:"Redirects,omitempty"`      // "follow" or "none"
+	Insecure       bool              `json:"Insecure,omitempty"`       // Skip TLS verification
+	WarnResponseMS int               `json:"WarnResponseMS,omitempty"` // Response time thresholds
+	CritResponseMS int               `json:"CritResponseMS,omitempty"`
+	bodyRegex      *regexp.Regexp    // Compiled BodyRegex, set by validate
+}
+
+func (h *httpCheck) validate() error {
+	if h.URL == "" {
+		return errors.New("HTTP check without URL")
+	}
+	switch h.Redirects {
+	case "", httpRedirectFollow, httpRedirectNone:
+	default:
+		return fmt.Errorf("unknown HTTP redirect policy '%s'", h.Redirects)
+	}
+	if h.BodyRegex != "" {
+		regex, err := regexp.Compile(h.BodyRegex)
+		if err != nil {
+			return err
+		}
+		h.bodyRegex = regex
+	}
+	return nil
+}
+
+// The compiled BodyRegex, nil if none. Only compiled here if validate wasn't
+// called before.
+func (h *httpCheck) bodyRegexp() (*regexp.Regexp, error) {
+	if h.bodyRegex != nil || h.BodyRegex == "" {
+		return h.bodyRegex, nil
+	}
+	return regexp.Compile(h.BodyRegex)
+}
+
+func (h *httpCheck) run(ctx context.Context) checkResult {
+	bodyRegex, err := h.bodyRegexp()
+	if err != nil {
+		return resultf(nagiosUnknown, "HTTP UNKNOWN: %v", err)
+	}
+
+	method := h.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var reqBody io.Reader
+	if h.Body != "" {
+		reqBody = strings.NewReader(h.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, h.URL, reqBody)
+	if err != nil {
+		return resultf(nagiosUnknown, "HTTP UNKNOWN: %v", err)
+	}
+	for key, value := range h.Headers {
+		if strings.EqualFold(key, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(key, value)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: h.Insecure},
+		},
+	}
+	if h.Redirects == httpRedirectNone {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	defer client.CloseIdleConnections()
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return resultf(nagiosCritical, "HTTP CRITICAL: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, httpMaxBodySize))
+	elapsed := time.Since(start)
+	if err != nil {
+		return resultf(nagiosCritical, "HTTP CRITICAL: %v", err)
+	}
+
+	status := thresholdStatus(float64(elapsed.Milliseconds()),
+		float64(h.WarnResponseMS), float64(h.CritResponseMS))
+
+	failures := h.evaluate(resp, body, bodyRegex)
+	if len(failures) > 0 {
+		status = nagiosCritical
+	}
+
+	output := fmt.Sprintf("HTTP %s: %s %s - %d bytes in %.3f second response time",
+		status.Str(), resp.Proto, resp.Status, len(body), elapsed.Seconds())
+	if len(failures) > 0 {
+		output += " - " + strings.Join(failures, ", ")
+	}
+
+	return checkResult{
+		status:   status,
+		output:   output,
+		perfData: h.perfData(elapsed, len(body)),
+	}
+}
+
+// Evaluate the response against all expectations and return the failed ones.
+func (h *httpCheck) evaluate(resp *http.Response, body []byte, bodyRegex *regexp.Regexp) (failures []string) {
+	if len(h.ExpectStatus) > 0 {
+		if !slices.Contains(h.ExpectStatus, resp.StatusCode) {
+			failures = append(failures, fmt.Sprintf("unexpected status code %d", resp.StatusCode))
+		}
+	} else if resp.StatusCode >= 400 {
+		failures = append(failures, fmt.Sprintf("unexpected status code %d", resp.StatusCode))
+	}
+
+	if h.BodyContains != "" && !strings.Contains(string(body), h.BodyContains) {
+		failures = append(failures, fmt.Sprintf("body doesn't contain '%s'", h.BodyContains))
+	}
+
+	if bodyRegex != nil && !bodyRegex.Match(body) {
+		failures = append(failures, fmt.Sprintf("body doesn't match '%s'", h.BodyRegex))
+	}
+
+	if len(h.JSONPath) == 0 {
+		return
+	}
+
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return append(failures, fmt.Sprintf("body is not JSON: %v", err))
+	}
+
+	paths := make([]string, 0, len(h.JSONPath))
+	for path := range h.JSONPath {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		if err := expectJSONPath(doc, path, h.JSONPath[path]); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	return
+}
+
+func (h *httpCheck) perfData(elapsed time.Duration, size int) perfData {
+	zero := 0.0
+	return perfData{
+		{
+			Label: "time",
+			Value: elapsed.Seconds(),
+			UOM:   "s",
+			Warn:  formatThreshold(float64(h.WarnResponseMS) / 1000),
+			Crit:  formatThreshold(float64(h.CritResponseMS) / 1000),
+			Min:   &zero,
+		},
+		{Label: "size", Value: float64(size), UOM: "B", Min: &zero},
+	}
+}
+
+// Look up a simple dotted path (e.g. "items.0.name", optionally prefixed with
+// "$.") in a decoded JSON document and compare it with the expected value.
+func expectJSONPath(doc any, path, expected string) error {
+	value := doc
+	for _, key := range strings.Split(strings.TrimPrefix(strings.TrimPrefix(path, "$"), "."), ".") {
+		if key == "" {
+			continue
+		}
+		switch node := value.(type) {
+		case map[string]any:
+			var ok bool
+			if value, ok = node[key]; !ok {
+				return fmt.Errorf("JSON path '%s' not found", path)
+			}
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return fmt.Errorf("JSON path '%s' not found", path)
+			}
+			value = node[i]
+		default:
+			return fmt.Errorf("JSON path '%s' not found", path)
+		}
+	}
+
+	var actual string
+	switch v := value.(type) {
+	case string:
+		actual = v
+	case float64:
+		actual = strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		actual = "null"
+	case bool:
+		actual = strconv.FormatBool(v)
+	default:
+		bytes, _ := json.Marshal(v)
+		actual = string(bytes)
+	}
+
+	if actual != expected {
+		return fmt.Errorf("JSON path '%s' is '%s', expected '%s'", path, actual, expected)
+	}
+	return nil
+}
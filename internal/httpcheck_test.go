@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/json", http.StatusFound)
+		case "/json":
+			if r.Header.Get("X-Token") != "secret" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"status":"up","items":[{"count":3}]}`))
+		case "/large":
+			w.Write([]byte(strings.Repeat("x", httpMaxBodySize+1)))
+		case "/slow":
+			time.Sleep(50 * time.Millisecond)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	headers := map[string]string{"X-Token": "secret"}
+	tests := []checkTest{
+		{
+			name: "JSON path",
+			check: check{HTTP: &httpCheck{URL: server.URL + "/json", Headers: headers,
+				JSONPath: map[string]string{"$.status": "up", "items.0.count": "3"}}},
+			expected: nagiosOk,
+		},
+		{
+			name: "JSON path mismatch",
+			check: check{HTTP: &httpCheck{URL: server.URL + "/json", Headers: headers,
+				JSONPath: map[string]string{"status": "down"}}},
+			expected: nagiosCritical,
+			output:   "JSON path 'status' is 'up', expected 'down'",
+		},
+		{
+			name:     "missing header",
+			check:    check{HTTP: &httpCheck{URL: server.URL + "/json"}},
+			expected: nagiosCritical,
+			output:   "unexpected status code 403",
+		},
+		{
+			name:     "body size limited",
+			check:    check{HTTP: &httpCheck{URL: server.URL + "/large"}},
+			expected: nagiosOk,
+			output:   fmt.Sprintf("%d bytes", httpMaxBodySize),
+		},
+		{
+			name:     "follow redirect",
+			check:    check{HTTP: &httpCheck{URL: server.URL + "/redirect", Headers: headers, BodyContains: `"up"`}},
+			expected: nagiosOk,
+		},
+		{
+			name:     "don't follow redirect",
+			check:    check{HTTP: &httpCheck{URL: server.URL + "/redirect", Redirects: httpRedirectNone, ExpectStatus: []int{302}}},
+			expected: nagiosOk,
+		},
+		{
+			name:     "body regex",
+			check:    check{HTTP: &httpCheck{URL: server.URL + "/json", Headers: headers, BodyRegex: `"count":\s*[0-9]+`}},
+			expected: nagiosOk,
+		},
+		{
+			name:     "slow response",
+			check:    check{HTTP: &httpCheck{URL: server.URL + "/slow", WarnResponseMS: 10}},
+			expected: nagiosWarning,
+		},
+		{
+			name:     "connection refused",
+			check:    check{HTTP: &httpCheck{URL: "http://127.0.0.1:1/"}},
+			expected: nagiosCritical,
+		},
+	}
+
+	runCheckTests(t, tests)
+}
+
+func TestHTTPCheckInvalidBodyRegex(t *testing.T) {
+	h := httpCheck{URL: "http://127.0.0.1:1/", BodyRegex: "(unclosed"}
+	if err := h.validate(); err == nil {
+		t.Error("expected an invalid BodyRegex to fail validation")
+	}
+	expectResult(t, h.run(context.Background()), nagiosUnknown, "HTTP UNKNOWN: ")
+
+	conf := config{LockMode: lockModeSkip, Checks: map[string]check{
+		"Broken HTTP":     {HTTP: &h},
+		"Broken Flapping": {Plugin: "/bin/true", FlapLowThreshold: 50, FlapHighThreshold: 20},
+	}}
+	err := conf.sanityCheck()
+	if err == nil || !strings.Contains(err.Error(), "Broken HTTP") || !strings.Contains(err.Error(), "Broken Flapping") {
+		t.Errorf("expected all config errors to be reported, got %v", err)
+	}
+}
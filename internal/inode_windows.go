@@ -0,0 +1,9 @@
+package internal
+
+import "os"
+
+// There are no inodes on Windows, so log rotation is only detected when the
+// file got shorter.
+func fileInode(os.FileInfo) uint64 {
+	return 0
+}
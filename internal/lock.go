@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// What to do when a previous Gogios run still holds the lock.
+const (
+	lockModeWait  = "wait"  // Wait until the lock is free or the global timeout is hit
+	lockModeSkip  = "skip"  // Silently skip this run
+	lockModeAlert = "alert" // Skip this run and send an error notification
+)
+
+var errLocked = errors.New("another Gogios run is still active")
+
+type runLock struct {
+	file *os.File
+}
+
+// Take the run lock according to the configured LockMode. Returns nil if this
+// run must not proceed.
+func takeRunLock(ctx context.Context, conf config) *runLock {
+	lock, err := acquireRunLock(ctx, conf)
+	if err == nil {
+		return lock
+	}
+
+	if errors.Is(err, errLocked) && conf.LockMode == lockModeSkip {
+		log.Println("Skipping run:", err)
+		return nil
+	}
+	notifyError(conf, err)
+	return nil
+}
+
+func acquireRunLock(ctx context.Context, conf config) (*runLock, error) {
+	if err := os.MkdirAll(conf.StateDir, 0o755); err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("%s/gogios.lock", conf.StateDir)
+
+	for {
+		lock, err := tryRunLock(path)
+		if err == nil || !errors.Is(err, errLocked) || conf.LockMode != lockModeWait {
+			return lock, err
+		}
+
+		log.Printf("Waiting for lock: %v", err)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", err, ctx.Err())
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func tryRunLock(path string) (*runLock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(file); err != nil {
+		file.Close()
+		if !errors.Is(err, errLocked) {
+			return nil, err
+		}
+		// The OS releases the lock when its holder exits, so a lock
+		// can't be stale.
+		if pid := readLockPid(path); pid > 0 {
+			return nil, fmt.Errorf("%w: lock held by PID %d", err, pid)
+		}
+		return nil, err
+	}
+
+	if err := file.Truncate(0); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.WriteAt([]byte(fmt.Sprintf("%d\n", os.Getpid())), 0); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &runLock{file}, nil
+}
+
+func readLockPid(path string) int {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(bytes)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// The lock file itself is kept, as removing it would race with other runs
+// which have it opened already.
+func (l *runLock) release() {
+	if err := l.file.Truncate(0); err != nil {
+		log.Println("error:", err)
+	}
+	if err := unlockFile(l.file); err != nil {
+		log.Println("error:", err)
+	}
+	l.file.Close()
+}
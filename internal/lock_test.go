@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunLock(t *testing.T) {
+	conf := config{StateDir: t.TempDir(), LockMode: lockModeSkip}
+	ctx := context.Background()
+
+	lock, err := acquireRunLock(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = acquireRunLock(ctx, conf)
+	if !errors.Is(err, errLocked) {
+		t.Errorf("expected lock error, got %v", err)
+	} else if holder := fmt.Sprintf("held by PID %d", os.Getpid()); !strings.Contains(err.Error(), holder) {
+		t.Errorf("expected lock error to contain '%s', got %v", holder, err)
+	}
+
+	conf.LockMode = lockModeWait
+	waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := acquireRunLock(waitCtx, conf); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected timeout while waiting for lock, got %v", err)
+	}
+
+	lock.release()
+	lock, err = acquireRunLock(ctx, conf)
+	if err != nil {
+		t.Fatalf("expected lock to be free again, got %v", err)
+	}
+	lock.release()
+}
@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Don't put more matching lines than this into the long output.
+const maxLogMatches = 10
+
+// Built-in log file check, scanning only the lines added since the last run.
+type logCheck struct {
+	File      string
+	Critical  []string `json:"Critical,omitempty"` // Regexes of lines resulting in CRITICAL
+	Warning   []string `json:"Warning,omitempty"`  // Regexes of lines resulting in WARNING
+	Ignore    []string `json:"Ignore,omitempty"`   // Regexes of lines never to match
+	stateFile string
+}
+
+// Where the previous run stopped reading. A different inode or a file shorter
+// than the offset means the log was rotated or truncated in the meantime.
+type logOffset struct {
+	Inode  uint64
+	Offset int64
+}
+
+func (l *logCheck) setStateFile(file string) {
+	l.stateFile = file
+}
+
+func (l *logCheck) validate() error {
+	if l.File == "" {
+		return errors.New("log check without File")
+	}
+	if len(l.Critical) == 0 && len(l.Warning) == 0 {
+		return errors.New("log check without Critical or Warning patterns")
+	}
+	_, _, _, err := l.regexes()
+	return err
+}
+
+func (l *logCheck) regexes() (critical, warning, ignore []*regexp.Regexp, err error) {
+	compile := func(patterns []string) ([]*regexp.Regexp, error) {
+		var regexes []*regexp.Regexp
+		for _, pattern := range patterns {
+			regex, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, err
+			}
+			regexes = append(regexes, regex)
+		}
+		return regexes, nil
+	}
+
+	if critical, err = compile(l.Critical); err != nil {
+		return
+	}
+	if warning, err = compile(l.Warning); err != nil {
+		return
+	}
+	ignore, err = compile(l.Ignore)
+	return
+}
+
+func (l *logCheck) run(ctx context.Context) checkResult {
+	critical, warning, ignore, err := l.regexes()
+	if err != nil {
+		return resultf(nagiosUnknown, "LOG UNKNOWN: %v", err)
+	}
+
+	file, err := os.Open(l.File)
+	if err != nil {
+		return resultf(nagiosUnknown, "LOG UNKNOWN: %v", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return resultf(nagiosUnknown, "LOG UNKNOWN: %v", err)
+	}
+	current := logOffset{Inode: fileInode(info), Offset: info.Size()}
+
+	var last logOffset
+	switch err := readCheckState(l.stateFile, &last); {
+	case errors.Is(err, os.ErrNotExist):
+		// First run, only new lines from now on are of interest.
+		last = current
+	case err != nil:
+		return resultf(nagiosUnknown, "LOG UNKNOWN: %v", err)
+	case last.Inode != current.Inode || last.Offset > current.Offset:
+		last.Offset = 0
+	}
+
+	if _, err := file.Seek(last.Offset, io.SeekStart); err != nil {
+		return resultf(nagiosUnknown, "LOG UNKNOWN: %v", err)
+	}
+
+	var (
+		status                     nagiosCode
+		numLines, numCrit, numWarn int
+		matches                    []string
+		offset                     = last.Offset
+		reader                     = bufio.NewReader(file)
+	)
+
+	for ctx.Err() == nil {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// An incomplete last line is read again on the next run.
+			break
+		}
+		offset += int64(len(line))
+		numLines++
+
+		line = strings.TrimRight(line, "\r\n")
+		if matchesAny(ignore, line) {
+			continue
+		}
+		switch {
+		case matchesAny(critical, line):
+			numCrit++
+			status = nagiosCritical
+		case matchesAny(warning, line):
+			numWarn++
+			status = max(status, nagiosWarning)
+		default:
+			continue
+		}
+		matches = append(matches, line)
+		if len(matches) > maxLogMatches {
+			matches = matches[1:]
+		}
+	}
+	if ctx.Err() != nil {
+		return resultf(nagiosUnknown, "LOG UNKNOWN: %v", ctx.Err())
+	}
+
+	if err := writeCheckState(l.stateFile, logOffset{Inode: current.Inode, Offset: offset}); err != nil {
+		return resultf(nagiosUnknown, "LOG UNKNOWN: %v", err)
+	}
+
+	output := fmt.Sprintf("LOG %s - %d critical and %d warning lines out of %d new lines in %s",
+		status.Str(), numCrit, numWarn, numLines, l.File)
+	if len(matches) > 0 {
+		output += ": " + matches[len(matches)-1]
+	}
+
+	zero := 0.0
+	return checkResult{
+		status:     status,
+		output:     output,
+		longOutput: strings.Join(matches, "\n"),
+		perfData: perfData{
+			{Label: "lines", Value: float64(numLines), Min: &zero},
+			{Label: "critical", Value: float64(numCrit), Min: &zero},
+			{Label: "warning", Value: float64(numWarn), Min: &zero},
+		},
+	}
+}
+
+func matchesAny(regexes []*regexp.Regexp, line string) bool {
+	for _, regex := range regexes {
+		if regex.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
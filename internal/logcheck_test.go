@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogCheck(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "messages")
+
+	appendLog := func(lines string) {
+		file, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		if _, err := file.WriteString(lines); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logCheck := logCheck{
+		File:     logFile,
+		Critical: []string{"ERROR"},
+		Warning:  []string{"WARN"},
+		Ignore:   []string{"harmless"},
+	}
+	if err := logCheck.validate(); err != nil {
+		t.Fatal(err)
+	}
+	logCheck.setStateFile(checkStateFile(dir, "Log messages"))
+
+	steps := []struct {
+		name     string
+		prepare  func()
+		expected nagiosCode
+		output   string
+	}{
+		{
+			name:     "first run skips existing lines",
+			prepare:  func() { appendLog("old ERROR\n") },
+			expected: nagiosOk,
+			output:   "out of 0 new lines",
+		},
+		{
+			name:     "new lines",
+			prepare:  func() { appendLog("foo WARN\nbar ERROR\nharmless ERROR\n") },
+			expected: nagiosCritical,
+			output:   "1 critical and 1 warning lines out of 3 new lines in " + logFile + ": bar ERROR",
+		},
+		{
+			name:     "no new lines",
+			prepare:  func() {},
+			expected: nagiosOk,
+			output:   "out of 0 new lines",
+		},
+		{
+			name:     "incomplete line",
+			prepare:  func() { appendLog("WARN not yet") },
+			expected: nagiosOk,
+			output:   "out of 0 new lines",
+		},
+		{
+			name:     "completed line",
+			prepare:  func() { appendLog(" complete\n") },
+			expected: nagiosWarning,
+			output:   ": WARN not yet complete",
+		},
+		{
+			name: "rotated",
+			prepare: func() {
+				if err := os.Rename(logFile, logFile+".0"); err != nil {
+					t.Fatal(err)
+				}
+				appendLog("rotated ERROR\n")
+			},
+			expected: nagiosCritical,
+			output:   "out of 1 new lines",
+		},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			step.prepare()
+			result := check{Log: &logCheck}.run(context.Background(), "Log messages")
+			expectResult(t, result, step.expected, step.output)
+		})
+	}
+}
+
+func TestLogCheckStateFile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "gogios.json")
+	config := `{"StateDir": "/var/run/gogios", "Checks": {"Log /var/log/messages": {"Log": {"File": "/var/log/messages", "Critical": ["panic"]}}}}`
+	if err := os.WriteFile(configFile, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := newConfig(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateFile := conf.Checks["Log /var/log/messages"].Log.stateFile
+	if dir, file := filepath.Split(stateFile); dir != "/var/run/gogios/" ||
+		!strings.HasPrefix(file, "check-Log__var_log_messages-") {
+		t.Errorf("unexpected state file '%s'", stateFile)
+	}
+}
+
+func TestLogCheckRetries(t *testing.T) {
+	log := &logCheck{File: "/var/log/messages", Critical: []string{"panic"}}
+
+	if err := (check{Log: log, MaxAttempts: 1}).validate(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	for _, c := range []check{{Log: log, Retries: 2}, {Log: log, MaxAttempts: 3}} {
+		if err := c.validate(); err == nil {
+			t.Error("expected log check with Retries or MaxAttempts to be invalid")
+		}
+	}
+}
@@ -7,6 +7,8 @@ const (
 	nagiosWarning  nagiosCode = 1
 	nagiosCritical nagiosCode = 2
 	nagiosUnknown  nagiosCode = 3
+	// Not a plugin exit code: A dependency of the check is not OK.
+	nagiosUnreachable nagiosCode = 4
 )
 
 func (n nagiosCode) Str() string {
@@ -17,6 +19,8 @@ func (n nagiosCode) Str() string {
 		return "WARNING"
 	case nagiosCritical:
 		return "CRITICAL"
+	case nagiosUnreachable:
+		return "UNREACHABLE"
 	default:
 		return "UNKNOWN"
 	}
@@ -0,0 +1,207 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A built-in check type, configured in the check instead of a Plugin.
+type nativeCheck interface {
+	validate() error
+	run(ctx context.Context) checkResult
+}
+
+// A built-in check type keeping state of its own between runs, in a file in
+// the StateDir.
+type statefulCheck interface {
+	setStateFile(file string)
+}
+
+// All built-in check types configured for the check, should be at most one.
+func (c check) natives() []nativeCheck {
+	var natives []nativeCheck
+	if c.HTTP != nil {
+		natives = append(natives, c.HTTP)
+	}
+	if c.TLS != nil {
+		natives = append(natives, c.TLS)
+	}
+	if c.Port != nil {
+		natives = append(natives, c.Port)
+	}
+	if c.DNS != nil {
+		natives = append(natives, c.DNS)
+	}
+	if c.Disk != nil {
+		natives = append(natives, c.Disk)
+	}
+	if c.Log != nil {
+		natives = append(natives, c.Log)
+	}
+	if c.File != nil {
+		natives = append(natives, c.File)
+	}
+	if c.NRPE != nil {
+		natives = append(natives, c.NRPE)
+	}
+	if c.Agent != nil {
+		natives = append(natives, c.Agent)
+	}
+	if c.Heartbeat != nil {
+		natives = append(natives, c.Heartbeat)
+	}
+	if c.Passive != nil {
+		natives = append(natives, c.Passive)
+	}
+	return natives
+}
+
+func (c check) native() nativeCheck {
+	if natives := c.natives(); len(natives) > 0 {
+		return natives[0]
+	}
+	return nil
+}
+
+func (c check) validate() error {
+	natives := c.natives()
+	switch {
+	case c.SSH != nil && len(natives) > 0:
+		return errors.New("SSH is only supported for plugins")
+	case c.SSH != nil && c.Plugin != "":
+		return c.SSH.validate()
+	case len(natives) > 1:
+		return errors.New("more than one check type configured")
+	case len(natives) == 1 && c.Plugin != "":
+		return errors.New("both a Plugin and a built-in check type configured")
+	case len(natives) == 0 && c.Plugin == "":
+		return errors.New("neither a Plugin nor a built-in check type configured")
+	case c.Log != nil && (c.Retries > 0 || c.MaxAttempts > 1):
+		// A retry or the next attempt would only scan the lines added since,
+		// so a soft state could never become a hard one.
+		return errors.New("Retries and MaxAttempts aren't supported for log checks")
+	case len(natives) == 1:
+		return natives[0].validate()
+	}
+	return nil
+}
+
+func (c check) runNative(ctx context.Context, native nativeCheck) checkResult {
+	result := native.run(ctx)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.output = "Check timed out"
+		result.status = nagiosCritical
+	}
+	return result
+}
+
+// Make a blocking call, e.g. a syscall on a hung NFS mount, giving up when the
+// context is done. The call itself can't be interrupted, it returns in the
+// background (if ever).
+func withContext[T any](ctx context.Context, call func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		value, err := call()
+		resultCh <- result{value, err}
+	}()
+
+	select {
+	case result := <-resultCh:
+		return result.value, result.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Evaluate a value against warning and critical thresholds, a threshold of 0
+// is disabled.
+func thresholdStatus(value, warn, crit float64) nagiosCode {
+	switch {
+	case crit > 0 && value >= crit:
+		return nagiosCritical
+	case warn > 0 && value >= warn:
+		return nagiosWarning
+	default:
+		return nagiosOk
+	}
+}
+
+// Format a threshold for perf data, where a disabled threshold is empty.
+func formatThreshold(threshold float64) string {
+	if threshold <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(threshold, 'f', -1, 64)
+}
+
+// The file a stateful built-in check keeps its state in, alongside state.json.
+func checkStateFile(stateDir, name string) string {
+	return filepath.Join(stateDir, "check-"+safeFileName(name)+".json")
+}
+
+// A file name for a check name, which may contain any character. The checksum
+// keeps names apart which only differ in replaced characters.
+func safeFileName(name string) string {
+	safeName := strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '.') {
+			return '_'
+		}
+		return r
+	}, name)
+	return fmt.Sprintf("%s-%08x", safeName, crc32.ChecksumIEEE([]byte(name)))
+}
+
+// Read the state of a stateful built-in check, which doesn't exist on the first
+// run (os.ErrNotExist).
+func readCheckState(file string, v any) error {
+	bytes, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, v)
+}
+
+// Written atomically, as e.g. heartbeats are written by other processes.
+func writeCheckState(file string, v any) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if err := tmpFile.Chmod(0o644); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if _, err := tmpFile.Write(bytes); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), file)
+}
+
+func resultf(status nagiosCode, format string, args ...any) checkResult {
+	return checkResult{status: status, output: fmt.Sprintf(format, args...)}
+}
@@ -1,22 +1,119 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/smtp"
 )
 
-func notify(conf config, subject, body string) error {
-	if conf.SMTPDisable {
+// A channel delivering the reports, configured in the Notifiers section.
+type notifier interface {
+	name() string
+	enabled() bool
+	validate() error
+	notify(n notification) error
+}
+
+// A report (or error) to deliver, with the checks whose status changed.
+type notification struct {
+	Subject string
+	Body    string
+	Changes []checkChange
+	Error   bool // The Body is an error message, not a report
+}
+
+type notifiers struct {
+	SMTP    *smtpNotifier    `json:"SMTP,omitempty"`
+	Webhook *webhookNotifier `json:"Webhook,omitempty"`
+	Command *commandNotifier `json:"Command,omitempty"`
+	Ntfy    *ntfyNotifier    `json:"Ntfy,omitempty"`
+	Gotify  *gotifyNotifier  `json:"Gotify,omitempty"`
+}
+
+// All configured notifiers, enabled or not.
+func (n notifiers) all() []notifier {
+	var all []notifier
+	if n.SMTP != nil {
+		all = append(all, n.SMTP)
+	}
+	if n.Webhook != nil {
+		all = append(all, n.Webhook)
+	}
+	if n.Command != nil {
+		all = append(all, n.Command)
+	}
+	if n.Ntfy != nil {
+		all = append(all, n.Ntfy)
+	}
+	if n.Gotify != nil {
+		all = append(all, n.Gotify)
+	}
+	return all
+}
+
+// Deliver the report to all enabled notifiers. A failing notifier doesn't
+// keep the others from delivering, all failures are returned together.
+func notify(conf config, n notification) error {
+	log.Println("notify", n.Subject, n.Body)
+
+	var errs []error
+	delivered := 0
+	for _, notifier := range conf.Notifiers.all() {
+		if !notifier.enabled() {
+			continue
+		}
+		delivered++
+		if err := notifier.notify(n); err != nil {
+			log.Printf("error: %s notifier: %v", notifier.name(), err)
+			errs = append(errs, fmt.Errorf("%s notifier: %w", notifier.name(), err))
+		}
+	}
+
+	if delivered == 0 {
 		log.Println("Notification disabled")
-		return nil
 	}
-	log.Println("notify", subject, body)
+	return errors.Join(errs...)
+}
+
+func notifyError(conf config, err error) {
+	if err := notify(conf, notification{
+		Subject: fmt.Sprintf("GOGIOS: An error occured: %v", err),
+		Body:    err.Error(),
+		Error:   true,
+	}); err != nil {
+		log.Println("error: ", err)
+	}
+}
+
+// Sends the reports via a SMTP server, by default the MTA on the local host.
+type smtpNotifier struct {
+	Enable bool
+	To     string `json:"To,omitempty"`     // Default EmailTo
+	From   string `json:"From,omitempty"`   // Default EmailFrom
+	Server string `json:"Server,omitempty"` // Default SMTPServer
+}
+
+func (s *smtpNotifier) name() string {
+	return "SMTP"
+}
+
+func (s *smtpNotifier) enabled() bool {
+	return s.Enable
+}
+
+func (s *smtpNotifier) validate() error {
+	if s.To == "" || s.From == "" {
+		return errors.New("SMTP notifier without To or From (or EmailTo or EmailFrom)")
+	}
+	return nil
+}
 
+func (s *smtpNotifier) notify(n notification) error {
 	headers := map[string]string{
-		"From":         conf.EmailFrom,
-		"To":           conf.EmailTo,
-		"Subject":      subject,
+		"From":         s.From,
+		"To":           s.To,
+		"Subject":      n.Subject,
 		"MIME-Version": "1.0",
 		"Content-Type": "text/plain; charset=\"utf-8\"",
 	}
@@ -26,15 +123,8 @@ func notify(conf config, subject, body string) error {
 		header += fmt.Sprintf("%s: %s\r\n", k, v)
 	}
 
-	message := header + "\r\n" + body
-	log.Println("Using SMTP server", conf.SMTPServer)
+	message := header + "\r\n" + n.Body
+	log.Println("Using SMTP server", s.Server)
 
-	return smtp.SendMail(conf.SMTPServer, nil, conf.EmailFrom,
-		[]string{conf.EmailTo}, []byte(message))
-}
-
-func notifyError(conf config, err error) {
-	if err := notify(conf, fmt.Sprintf("GOGIOS: An error occured: %v", err), err.Error()); err != nil {
-		log.Println("error: ", err)
-	}
+	return smtp.SendMail(s.Server, nil, s.From, []string{s.To}, []byte(message))
 }
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNotifiers(t *testing.T) {
+	var received webhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	outFile := filepath.Join(t.TempDir(), "out")
+	conf := config{LockMode: lockModeSkip, Notifiers: notifiers{
+		// Fails, but doesn't keep the other notifiers from delivering.
+		SMTP:    &smtpNotifier{Enable: true, To: "root@localhost", From: "gogios@localhost", Server: "127.0.0.1:1"},
+		Webhook: &webhookNotifier{Enable: true, URL: server.URL},
+		Command: &commandNotifier{
+			Enable:  true,
+			Command: "/bin/sh",
+			Args:    []string{"-c", `{ echo "$GOGIOS_SUBJECT"; cat; } > "$0"`, outFile},
+		},
+	}}
+	if err := conf.sanityCheck(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := notify(conf, notification{
+		Subject: "GOGIOS Report [C:1 W:0 U:0 S:0 OK:1]",
+		Body:    "This is the recent Gogios report!\n",
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "SMTP notifier: ") {
+		t.Errorf("expected only the SMTP notifier to fail, got %v", err)
+	}
+
+	if received.Subject != "GOGIOS Report [C:1 W:0 U:0 S:0 OK:1]" || received.Body != "This is the recent Gogios report!\n" {
+		t.Errorf("unexpected webhook report: %+v", received)
+	}
+	out, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "GOGIOS Report [C:1 W:0 U:0 S:0 OK:1]\nThis is the recent Gogios report!\n" {
+		t.Errorf("unexpected command notifier input: %q", out)
+	}
+
+	// Disabled notifiers are neither validated nor used.
+	conf.Notifiers = notifiers{Webhook: &webhookNotifier{}, Command: &commandNotifier{}}
+	if err := conf.sanityCheck(); err != nil {
+		t.Error(err)
+	}
+	if err := notify(conf, notification{Subject: "subject", Body: "body"}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,276 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// NRPE protocol constants, see common.h of NRPE.
+const (
+	nrpeQueryPacket    = 1
+	nrpeResponsePacket = 2
+
+	nrpeV2BufferSize  = 1024
+	nrpeV2PacketSize  = 1036 // Including two bytes of struct padding
+	nrpeV3HeaderSize  = 16
+	nrpeMaxBufferSize = 1 << 20 // Don't trust the server any further
+)
+
+// TLS modes of the NRPE check
+const (
+	nrpeTLSVerify   = "verify"   // Verify the server certificate (default)
+	nrpeTLSNoVerify = "noverify" // Encrypt, but don't verify the server certificate
+	nrpeTLSNone     = "none"     // Plain text, as with check_nrpe -n
+)
+
+// Handshake failure alert, which is what NRPE servers only offering anonymous
+// DH cipher suites respond with to regular TLS clients.
+const tlsAlertHandshakeFailure = 40
+
+// crypto/tls reports alerts sent by the server as a "remote error" with an
+// unexported error type, so it is recognized by its message.
+func isHandshakeFailureAlert(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "remote error" &&
+		opErr.Err.Error() == tls.AlertError(tlsAlertHandshakeFailure).Error()
+}
+
+// Built-in NRPE client, replacing check_nrpe.
+type nrpeCheck struct {
+	Host       string
+	Command    string
+	Port       int      `json:"Port,omitempty"`       // Default 5666
+	Args       []string `json:"Args,omitempty"`       // Requires dont_blame_nrpe=1 on the server
+	Version    int      `json:"Version,omitempty"`    // 2 or 3, default 3 falling back to 2
+	TLS        string   `json:"TLS,omitempty"`        // "verify", "noverify" or "none"
+	ServerName string   `json:"ServerName,omitempty"` // Default Host
+	CAFile     string   `json:"CAFile,omitempty"`     // PEM roots instead of the system ones
+	CertFile   string   `json:"CertFile,omitempty"`   // Client certificate and key, if required
+	KeyFile    string   `json:"KeyFile,omitempty"`
+}
+
+func (n *nrpeCheck) validate() error {
+	if n.Host == "" || n.Command == "" {
+		return errors.New("NRPE check without Host or Command")
+	}
+	switch n.Version {
+	case 0, 2, 3:
+	default:
+		return fmt.Errorf("unsupported NRPE version %d", n.Version)
+	}
+	switch n.TLS {
+	case "", nrpeTLSVerify, nrpeTLSNoVerify, nrpeTLSNone:
+	default:
+		return fmt.Errorf("unknown NRPE TLS mode '%s'", n.TLS)
+	}
+	for _, arg := range append([]string{n.Command}, n.Args...) {
+		if strings.Contains(arg, "!") {
+			return fmt.Errorf("NRPE command or argument '%s' contains '!'", arg)
+		}
+	}
+	if len(n.query()) >= nrpeV2BufferSize {
+		return errors.New("NRPE command with arguments too long")
+	}
+	if (n.CertFile == "") != (n.KeyFile == "") {
+		return errors.New("NRPE check with only one of CertFile and KeyFile")
+	}
+	if n.TLS != nrpeTLSNone {
+		if _, err := n.tlsConfig(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *nrpeCheck) query() string {
+	return strings.Join(append([]string{n.Command}, n.Args...), "!")
+}
+
+func (n *nrpeCheck) run(ctx context.Context) checkResult {
+	// Like check_nrpe, fall back to version 2 if the server doesn't answer
+	// version 3 queries, as NRPE 2 servers just close the connection.
+	versions := []int{3, 2}
+	if n.Version != 0 {
+		versions = []int{n.Version}
+	}
+
+	var err error
+	for _, version := range versions {
+		var (
+			code   nagiosCode
+			buffer string
+		)
+		if code, buffer, err = n.send(ctx, version); err == nil {
+			return nrpeResult(code, buffer)
+		}
+		if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) &&
+			!errors.Is(err, syscall.ECONNRESET) {
+			break
+		}
+	}
+	return resultf(nagiosCritical, "NRPE CRITICAL: %v", err)
+}
+
+// Evaluate the response just like the output and exit code of a plugin.
+func nrpeResult(code nagiosCode, buffer string) checkResult {
+	if code < nagiosOk || code > nagiosUnknown {
+		code = nagiosUnknown
+	}
+	if strings.TrimSpace(buffer) == "" {
+		buffer = "NRPE: No output returned from daemon"
+	}
+
+	output, longOutput, perfData := parsePluginOutput(buffer)
+	return checkResult{
+		status:     code,
+		output:     output,
+		longOutput: longOutput,
+		perfData:   perfData,
+	}
+}
+
+func (n *nrpeCheck) send(ctx context.Context, version int) (nagiosCode, string, error) {
+	conn, err := n.dial(ctx)
+	if err != nil {
+		return nagiosUnknown, "", err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(encodeNRPEPacket(version, nrpeQueryPacket, nagiosOk, n.query())); err != nil {
+		return nagiosUnknown, "", err
+	}
+
+	packetType, code, buffer, err := decodeNRPEPacket(conn)
+	if err != nil {
+		return nagiosUnknown, "", err
+	}
+	if packetType != nrpeResponsePacket {
+		return nagiosUnknown, "", fmt.Errorf("unexpected NRPE packet type %d", packetType)
+	}
+	return code, buffer, nil
+}
+
+func (n *nrpeCheck) dial(ctx context.Context) (net.Conn, error) {
+	port := n.Port
+	if port == 0 {
+		port = 5666
+	}
+	address := net.JoinHostPort(n.Host, strconv.Itoa(port))
+
+	var dialer net.Dialer
+	if n.TLS == nrpeTLSNone {
+		return dialer.DialContext(ctx, "tcp", address)
+	}
+
+	config, err := n.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	tlsDialer := tls.Dialer{NetDialer: &dialer, Config: config}
+	conn, err := tlsDialer.DialContext(ctx, "tcp", address)
+
+	if isHandshakeFailureAlert(err) {
+		return nil, fmt.Errorf("%w (the NRPE server may have no certificate, anonymous DH isn't supported, the NRPE server needs a certificate)", err)
+	}
+	return conn, err
+}
+
+func (n *nrpeCheck) tlsConfig() (*tls.Config, error) {
+	rootCAs, err := loadRootCAs(n.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		ServerName:         n.ServerName,
+		RootCAs:            rootCAs,
+		InsecureSkipVerify: n.TLS == nrpeTLSNoVerify,
+	}
+	if config.ServerName == "" {
+		config.ServerName = n.Host
+	}
+	if n.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(n.CertFile, n.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// Encode a packet, where version 2 packets have a fixed size buffer and
+// version 3 packets are at least as large as version 2 ones.
+func encodeNRPEPacket(version, packetType int, code nagiosCode, buffer string) []byte {
+	var packet []byte
+	if version == 2 {
+		packet = make([]byte, nrpeV2PacketSize)
+		copy(packet[10:10+nrpeV2BufferSize-1], buffer)
+	} else {
+		size := max(nrpeV3HeaderSize+len(buffer)+1, nrpeV2PacketSize)
+		packet = make([]byte, size)
+		binary.BigEndian.PutUint32(packet[12:], uint32(size-nrpeV3HeaderSize))
+		copy(packet[nrpeV3HeaderSize:], buffer)
+	}
+
+	binary.BigEndian.PutUint16(packet[0:], uint16(version))
+	binary.BigEndian.PutUint16(packet[2:], uint16(packetType))
+	binary.BigEndian.PutUint16(packet[8:], uint16(code))
+	binary.BigEndian.PutUint32(packet[4:], crc32.ChecksumIEEE(packet))
+	return packet
+}
+
+func decodeNRPEPacket(r io.Reader) (packetType int, code nagiosCode, buffer string, err error) {
+	header := make([]byte, nrpeV3HeaderSize)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+
+	var packet, buf []byte
+	switch version := binary.BigEndian.Uint16(header[0:]); version {
+	case 2:
+		packet = make([]byte, nrpeV2PacketSize)
+		buf = packet[10 : 10+nrpeV2BufferSize]
+	case 3:
+		size := binary.BigEndian.Uint32(header[12:])
+		if size > nrpeMaxBufferSize {
+			err = fmt.Errorf("NRPE packet buffer of %d bytes too large", size)
+			return
+		}
+		packet = make([]byte, nrpeV3HeaderSize+int(size))
+		buf = packet[nrpeV3HeaderSize:]
+	default:
+		err = fmt.Errorf("unsupported NRPE packet version %d", version)
+		return
+	}
+	copy(packet, header)
+	if _, err = io.ReadFull(r, packet[nrpeV3HeaderSize:]); err != nil {
+		return
+	}
+
+	crc := binary.BigEndian.Uint32(packet[4:])
+	binary.BigEndian.PutUint32(packet[4:], 0)
+	if crc32.ChecksumIEEE(packet) != crc {
+		err = errors.New("NRPE packet with invalid CRC32")
+		return
+	}
+
+	if end := strings.IndexByte(string(buf), 0); end >= 0 {
+		buf = buf[:end]
+	}
+	packetType = int(binary.BigEndian.Uint16(packet[2:]))
+	code = nagiosCode(int16(binary.BigEndian.Uint16(packet[8:])))
+	return packetType, code, string(buf), nil
+}
@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Serve NRPE queries, only of version 2 if v2Only (like NRPE 2 servers).
+func serveNRPE(t *testing.T, listener net.Listener, v2Only bool) int {
+	t.Helper()
+	t.Cleanup(func() { listener.Close() })
+
+	commands := map[string]struct {
+		code   nagiosCode
+		output string
+	}{
+		"check_load":       {nagiosOk, "OK - load average: 0.10|load1=0.1;5;10;0"},
+		"check_disk!/var":  {nagiosWarning, "DISK WARNING - /var 85% used\n/var/log 60% used"},
+		"check_raid!md0!2": {nagiosCritical, ""},
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				header, err := reader.Peek(2)
+				if err != nil {
+					return
+				}
+				version := int(binary.BigEndian.Uint16(header))
+				if v2Only && version != 2 {
+					return
+				}
+				packetType, _, query, err := decodeNRPEPacket(reader)
+				if err != nil || packetType != nrpeQueryPacket {
+					return
+				}
+				command, ok := commands[query]
+				if !ok {
+					command.code, command.output = nagiosUnknown, "NRPE: Command '"+query+"' not defined"
+				}
+				conn.Write(encodeNRPEPacket(version, nrpeResponsePacket, command.code, command.output))
+			}()
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestNRPECheck(t *testing.T) {
+	listen := func() net.Listener {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return listener
+	}
+
+	plainPort := serveNRPE(t, listen(), false)
+	v2Port := serveNRPE(t, listen(), true)
+
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer tlsServer.Close()
+	tlsPort := serveNRPE(t, tls.NewListener(listen(), tlsServer.TLS), false)
+	// Like an NRPE server without certificate, it has no cipher suite in
+	// common with Go's TLS client.
+	noCertConfig := tlsServer.TLS.Clone()
+	noCertConfig.MaxVersion = tls.VersionTLS12
+	noCertConfig.CipherSuites = []uint16{tls.TLS_RSA_WITH_RC4_128_SHA}
+	noCertPort := serveNRPE(t, tls.NewListener(listen(), noCertConfig), false)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw})
+	if err := os.WriteFile(caFile, pemBytes, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []checkTest{
+		{
+			name:     "plain",
+			check:    check{NRPE: &nrpeCheck{Host: "127.0.0.1", Port: plainPort, Command: "check_load", TLS: nrpeTLSNone}},
+			expected: nagiosOk,
+			output:   "OK - load average: 0.10",
+		},
+		{
+			name:     "arguments",
+			check:    check{NRPE: &nrpeCheck{Host: "127.0.0.1", Port: plainPort, Command: "check_disk", Args: []string{"/var"}, TLS: nrpeTLSNone}},
+			expected: nagiosWarning,
+			output:   "DISK WARNING - /var 85% used",
+		},
+		{
+			name:     "no output",
+			check:    check{NRPE: &nrpeCheck{Host: "127.0.0.1", Port: plainPort, Command: "check_raid", Args: []string{"md0", "2"}, TLS: nrpeTLSNone, Version: 2}},
+			expected: nagiosCritical,
+			output:   "No output returned from daemon",
+		},
+		{
+			name:     "fallback to version 2",
+			check:    check{NRPE: &nrpeCheck{Host: "127.0.0.1", Port: v2Port, Command: "check_load", TLS: nrpeTLSNone}},
+			expected: nagiosOk,
+			output:   "OK - load average: 0.10",
+		},
+		{
+			name:     "version 3 only",
+			check:    check{NRPE: &nrpeCheck{Host: "127.0.0.1", Port: v2Port, Command: "check_load", TLS: nrpeTLSNone, Version: 3}},
+			expected: nagiosCritical,
+			output:   "EOF",
+		},
+		{
+			name:     "unknown command",
+			check:    check{NRPE: &nrpeCheck{Host: "127.0.0.1", Port: plainPort, Command: "check_foo", TLS: nrpeTLSNone}},
+			expected: nagiosUnknown,
+			output:   "Command 'check_foo' not defined",
+		},
+		{
+			name:     "TLS verified",
+			check:    check{NRPE: &nrpeCheck{Host: "127.0.0.1", Port: tlsPort, Command: "check_load", CAFile: caFile, ServerName: "example.com"}},
+			expected: nagiosOk,
+			output:   "OK - load average: 0.10",
+		},
+		{
+			name:     "TLS not verified",
+			check:    check{NRPE: &nrpeCheck{Host: "127.0.0.1", Port: tlsPort, Command: "check_load", TLS: nrpeTLSNoVerify}},
+			expected: nagiosOk,
+		},
+		{
+			name:     "TLS unknown authority",
+			check:    check{NRPE: &nrpeCheck{Host: "127.0.0.1", Port: tlsPort, Command: "check_load"}},
+			expected: nagiosCritical,
+			output:   "NRPE CRITICAL: tls: failed to verify certificate",
+		},
+		{
+			name:     "TLS without certificate",
+			check:    check{NRPE: &nrpeCheck{Host: "127.0.0.1", Port: noCertPort, Command: "check_load", TLS: nrpeTLSNoVerify}},
+			expected: nagiosCritical,
+			output:   "the NRPE server needs a certificate",
+		},
+		{
+			name:     "connection refused",
+			check:    check{NRPE: &nrpeCheck{Host: "127.0.0.1", Port: 1, Command: "check_load", TLS: nrpeTLSNone}},
+			expected: nagiosCritical,
+			output:   "NRPE CRITICAL: ",
+		},
+	}
+
+	runCheckTests(t, tests)
+
+	result := check{NRPE: &nrpeCheck{Host: "127.0.0.1", Port: plainPort, Command: "check_load", TLS: nrpeTLSNone}}.
+		run(context.Background(), "perf data")
+	if result.perfData.String() != "load1=0.1;5;10;0" {
+		t.Errorf("unexpected perf data '%s'", result.perfData.String())
+	}
+
+	if err := (&nrpeCheck{Host: "foo.zone", Command: "check_disk", Args: []string{"a!b"}}).validate(); err == nil {
+		t.Error("expected argument with '!' to be invalid")
+	}
+}
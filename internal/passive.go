@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Passive check, which takes over the last result submitted from outside, via
+// gogios -submit or the HTTP endpoint. It becomes stale once nothing was
+// submitted for StaleThreshold seconds.
+type passiveCheck struct {
+	stateFile string
+}
+
+// A submitted check result, kept in the state file of the passive check.
+type submission struct {
+	Name     string
+	Status   nagiosCode
+	Output   string // Plugin output, may contain perf data and long output
+	PerfData string `json:"PerfData,omitempty"`
+	Epoch    int64  `json:"Epoch,omitempty"` // Set on submission
+}
+
+func (p *passiveCheck) setStateFile(file string) {
+	p.stateFile = file
+}
+
+func (p *passiveCheck) validate() error {
+	return nil
+}
+
+func (p *passiveCheck) run(context.Context) checkResult {
+	var sub submission
+	switch err := readCheckState(p.stateFile, &sub); {
+	case errors.Is(err, os.ErrNotExist):
+		return resultf(nagiosUnknown, "PASSIVE UNKNOWN - No result submitted yet")
+	case err != nil:
+		return resultf(nagiosUnknown, "PASSIVE UNKNOWN: %v", err)
+	}
+
+	output, longOutput, perfData := parsePluginOutput(sub.Output)
+	if sub.PerfData != "" {
+		data, _ := parsePerfData(sub.PerfData)
+		perfData = append(perfData, data...)
+	}
+	return checkResult{
+		output:     output,
+		longOutput: longOutput,
+		epoch:      sub.Epoch,
+		status:     sub.Status,
+		passive:    true,
+		perfData:   perfData,
+	}
+}
+
+// Submit a passive check result, as received by gogios -submit. The status is
+// either a Nagios exit code or its name, e.g. "2" or "CRITICAL".
+func Submit(configFile, name, status, output string) error {
+	conf, err := newConfig(configFile)
+	if err != nil {
+		return err
+	}
+
+	code, err := parseStatus(status)
+	if err != nil {
+		return err
+	}
+	return conf.submit(submission{Name: name, Status: code, Output: output})
+}
+
+func parseStatus(status string) (nagiosCode, error) {
+	if code, err := strconv.Atoi(status); err == nil {
+		return nagiosCode(code), nil
+	}
+	for code := nagiosOk; code <= nagiosUnknown; code++ {
+		if strings.EqualFold(status, code.Str()) {
+			return code, nil
+		}
+	}
+	return nagiosUnknown, fmt.Errorf("unknown status '%s'", status)
+}
+
+func (conf config) submit(sub submission) error {
+	passive, err := conf.passive(sub.Name)
+	if err != nil {
+		return err
+	}
+	if sub.Status < nagiosOk || sub.Status > nagiosUnknown {
+		return fmt.Errorf("invalid status %d", sub.Status)
+	}
+	if sub.PerfData != "" {
+		if _, err := parsePerfData(sub.PerfData); err != nil {
+			return err
+		}
+	}
+
+	sub.Epoch = time.Now().Unix()
+	return writeCheckState(passive.stateFile, sub)
+}
+
+func (conf config) passive(name string) (*passiveCheck, error) {
+	check, ok := conf.Checks[name]
+	if !ok {
+		return nil, fmt.Errorf("no such check '%s'", name)
+	}
+	if check.Passive == nil {
+		return nil, fmt.Errorf("check '%s' is not a passive check", name)
+	}
+	return check.Passive, nil
+}
@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubmitPassive(t *testing.T) {
+	conf := config{
+		StateDir:       t.TempDir(),
+		StaleThreshold: 3600,
+		ListenToken:    "secret",
+		Checks: map[string]check{
+			"Ping db1":   {Plugin: "/usr/lib/nagios/plugins/check_ping"},
+			"RAID db1":   {Passive: &passiveCheck{}},
+			"Backup db1": {Passive: &passiveCheck{}},
+		},
+	}
+	for name, check := range conf.Checks {
+		if check.Passive != nil {
+			check.Passive.setStateFile(checkStateFile(conf.StateDir, name))
+		}
+	}
+
+	if err := conf.submit(submission{Name: "Ping db1", Status: nagiosOk}); err == nil {
+		t.Error("expected submission of an active check to fail")
+	}
+	if err := conf.submit(submission{Name: "Typo db1", Status: nagiosOk}); err == nil {
+		t.Error("expected submission of an unknown check to fail")
+	}
+	if err := conf.submit(submission{Name: "RAID db1", Status: 7}); err == nil {
+		t.Error("expected submission with an invalid status to fail")
+	}
+
+	s := state{checks: make(map[string]checkState), staleEpoch: time.Now().Unix() - 3600}
+	result := namedCheck{conf.Checks["RAID db1"], "RAID db1"}.run(context.Background())
+	if result.status != nagiosUnknown || result.output != "PASSIVE UNKNOWN - No result submitted yet" {
+		t.Errorf("unexpected result without submission: %+v", result)
+	}
+
+	if err := conf.submit(submission{
+		Name:   "RAID db1",
+		Status: nagiosWarning,
+		Output: "RAID WARNING - md0 degraded|disks=1;;;0;2",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(conf.httpHandler())
+	defer server.Close()
+	post := func(token string) int {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/submit",
+			strings.NewReader(`{"Name": "Backup db1", "Status": 0, "Output": "Backup OK", "PerfData": "size=12GB"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := post("wrong"); status != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, status)
+	}
+	if status := post("secret"); status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+	}
+
+	for _, name := range []string{"RAID db1", "Backup db1"} {
+		s.update(namedCheck{conf.Checks[name], name}.run(context.Background()))
+	}
+	raid, ok := s.checks["RAID db1"]
+	if !ok || !raid.Passive || raid.Status != nagiosWarning || raid.Output != "RAID WARNING - md0 degraded" ||
+		raid.PerfData.String() != "disks=1;;;0;2" {
+		t.Errorf("unexpected state of the submitted result: %+v", raid)
+	}
+	if backup := s.checks["Backup db1"]; backup.PerfData.String() != "size=12GB" {
+		t.Errorf("unexpected perf data of the submitted result: %+v", backup)
+	}
+
+	// Without a new submission, the next run is no further attempt.
+	s.update(namedCheck{conf.Checks["RAID db1"], "RAID db1"}.run(context.Background()))
+	if raid := s.checks["RAID db1"]; raid.Status != nagiosWarning || raid.Attempts != 1 {
+		t.Errorf("expected the submitted result to be kept as is, got %+v", raid)
+	}
+
+	_, body, _ := s.report(false, false)
+	if !strings.Contains(body, "WARNING: RAID db1: RAID WARNING - md0 degraded [passive]") {
+		t.Errorf("expected the submitted result to be reported, got:\n%s", body)
+	}
+
+	// Without new submissions, the results become stale.
+	s.staleEpoch = time.Now().Unix() + 1
+	_, body, _ = s.report(false, false)
+	if stale := body[strings.Index(body, "# Stale alerts"):]; !strings.Contains(stale, "RAID db1") {
+		t.Errorf("expected the submitted result to be stale, got:\n%s", body)
+	}
+}
+
+func TestSubmitWithoutToken(t *testing.T) {
+	conf := config{
+		StateDir: t.TempDir(),
+		Checks:   map[string]check{"RAID db1": {Passive: &passiveCheck{}}},
+	}
+	conf.Checks["RAID db1"].Passive.setStateFile(checkStateFile(conf.StateDir, "RAID db1"))
+
+	// Anyone could submit results otherwise.
+	server := httptest.NewServer(conf.httpHandler())
+	defer server.Close()
+	resp, err := http.Post(server.URL+"/submit", "application/json",
+		strings.NewReader(`{"Name": "RAID db1", "Status": 0, "Output": "RAID OK"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	for str, expected := range map[string]nagiosCode{
+		"0":        nagiosOk,
+		"2":        nagiosCritical,
+		"warning":  nagiosWarning,
+		"UNKNOWN":  nagiosUnknown,
+		"CRITICAL": nagiosCritical,
+	} {
+		if code, err := parseStatus(str); err != nil || code != expected {
+			t.Errorf("%s: expected %s, got %s (%v)", str, expected.Str(), code.Str(), err)
+		}
+	}
+	if _, err := parseStatus("broken"); err == nil {
+		t.Error("expected an unknown status to fail")
+	}
+}
@@ -0,0 +1,253 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A single Nagios performance data item, e.g. 'rta'=0.1ms;50;100;0
+type perfDatum struct {
+	Label        string
+	Value        float64
+	Undetermined bool     `json:"Undetermined,omitempty"` // Value was reported as 'U'
+	UOM          string   `json:"UOM,omitempty"`
+	Warn         string   `json:"Warn,omitempty"` // Range, e.g. "10", "10:" or "@5:10"
+	Crit         string   `json:"Crit,omitempty"`
+	Min          *float64 `json:"Min,omitempty"`
+	Max          *float64 `json:"Max,omitempty"`
+}
+
+func (p perfDatum) String() string {
+	var sb strings.Builder
+
+	label := p.Label
+	if strings.ContainsAny(label, " '=") {
+		label = "'" + strings.ReplaceAll(label, "'", "''") + "'"
+	}
+	sb.WriteString(label)
+	sb.WriteString("=")
+
+	if p.Undetermined {
+		sb.WriteString("U")
+	} else {
+		sb.WriteString(strconv.FormatFloat(p.Value, 'f', -1, 64))
+		sb.WriteString(p.UOM)
+	}
+
+	formatFloat := func(f *float64) string {
+		if f == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*f, 'f', -1, 64)
+	}
+
+	fields := []string{p.Warn, p.Crit, formatFloat(p.Min), formatFloat(p.Max)}
+	for len(fields) > 0 && fields[len(fields)-1] == "" {
+		fields = fields[:len(fields)-1]
+	}
+	for _, field := range fields {
+		sb.WriteString(";")
+		sb.WriteString(field)
+	}
+
+	return sb.String()
+}
+
+type perfData []perfDatum
+
+func (p perfData) String() string {
+	strs := make([]string, len(p))
+	for i, datum := range p {
+		strs[i] = datum.String()
+	}
+	return strings.Join(strs, " ")
+}
+
+// Split plugin output into its first line, the long output and the performance
+// data. According to the Nagios plugin API, the first line may carry perf data
+// after a '|', and so may the long output: All lines after the first '|' in
+// the long output are perf data too.
+func parsePluginOutput(raw string) (string, string, perfData) {
+	var (
+		textLines []string
+		perfParts []string
+	)
+
+	lines := strings.Split(raw, "\n")
+	first, firstPerf, _ := strings.Cut(lines[0], "|")
+	textLines = append(textLines, strings.TrimRight(first, " \t"))
+	perfParts = append(perfParts, firstPerf)
+
+	inPerf := false
+	for _, line := range lines[1:] {
+		if inPerf {
+			perfParts = append(perfParts, line)
+			continue
+		}
+		text, perf, found := strings.Cut(line, "|")
+		textLines = append(textLines, strings.TrimRight(text, " \t"))
+		if found {
+			perfParts = append(perfParts, perf)
+			inPerf = true
+		}
+	}
+
+	var data perfData
+	for _, part := range perfParts {
+		// Malformed perf data must never make a check fail, so we just keep
+		// whatever could be parsed.
+		parsed, _ := parsePerfData(part)
+		data = append(data, parsed...)
+	}
+
+	output := strings.TrimSpace(textLines[0])
+	longOutput := strings.TrimSpace(strings.Join(textLines[1:], "\n"))
+	if output == "" {
+		// Some plugins start with an empty line
+		output, longOutput, _ = strings.Cut(longOutput, "\n")
+		longOutput = strings.TrimSpace(longOutput)
+	}
+
+	return output, longOutput, data
+}
+
+// Parse space separated perf data: 'label'=value[UOM];[warn];[crit];[min];[max]
+// Malformed items are skipped, the others are still parsed and the errors of
+// all skipped items are returned together.
+func parsePerfData(str string) (perfData, error) {
+	var (
+		data perfData
+		errs []error
+	)
+
+	for _, token := range tokenizePerfData(str) {
+		datum, err := parsePerfDatum(token)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		data = append(data, datum)
+	}
+
+	return data, errors.Join(errs...)
+}
+
+// Split on whitespace, but keep quoted labels (which may contain spaces and
+// quotes escaped by doubling them) together.
+func tokenizePerfData(str string) []string {
+	var (
+		tokens  []string
+		current strings.Builder
+		quoted  bool
+	)
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		switch {
+		case c == '\'':
+			if quoted && i+1 < len(str) && str[i+1] == '\'' {
+				current.WriteString("''")
+				i++
+				continue
+			}
+			quoted = !quoted
+			current.WriteByte(c)
+		case !quoted && (c == ' ' || c == '\t' || c == '\r' || c == '\n'):
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+func parsePerfDatum(token string) (perfDatum, error) {
+	var datum perfDatum
+
+	eq := strings.LastIndex(token, "=")
+	if eq <= 0 {
+		return datum, fmt.Errorf("invalid perf data '%s': missing label", token)
+	}
+
+	label := token[:eq]
+	if len(label) >= 2 && label[0] == '\'' && label[len(label)-1] == '\'' {
+		label = strings.ReplaceAll(label[1:len(label)-1], "''", "'")
+	}
+	if label == "" {
+		return datum, fmt.Errorf("invalid perf data '%s': empty label", token)
+	}
+	datum.Label = label
+
+	fields := strings.Split(token[eq+1:], ";")
+	if err := datum.parseValue(fields[0]); err != nil {
+		return datum, fmt.Errorf("invalid perf data '%s': %w", token, err)
+	}
+
+	if len(fields) > 1 {
+		datum.Warn = fields[1]
+	}
+	if len(fields) > 2 {
+		datum.Crit = fields[2]
+	}
+
+	var err error
+	if len(fields) > 3 {
+		if datum.Min, err = parseOptionalFloat(fields[3]); err != nil {
+			return datum, fmt.Errorf("invalid perf data '%s': min: %w", token, err)
+		}
+	}
+	if len(fields) > 4 {
+		if datum.Max, err = parseOptionalFloat(fields[4]); err != nil {
+			return datum, fmt.Errorf("invalid perf data '%s': max: %w", token, err)
+		}
+	}
+
+	return datum, nil
+}
+
+func (p *perfDatum) parseValue(str string) error {
+	if str == "U" {
+		p.Undetermined = true
+		return nil
+	}
+
+	end := 0
+	for end < len(str) && strings.ContainsRune("0123456789.-+eE", rune(str[end])) {
+		end++
+	}
+	// Don't mistake the 'e' of a UOM for an exponent, e.g. "10e"
+	for end > 0 && (str[end-1] == 'e' || str[end-1] == 'E') {
+		end--
+	}
+
+	value, err := strconv.ParseFloat(str[:end], 64)
+	if err != nil {
+		return fmt.Errorf("value '%s': %w", str, err)
+	}
+	p.Value = value
+	p.UOM = str[end:]
+
+	return nil
+}
+
+func parseOptionalFloat(str string) (*float64, error) {
+	if str == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestParsePerfData(t *testing.T) {
+	data, err := parsePerfData("time=0.12s;1;2;0;10 'used space'=80%;85;95 'it''s'=U count=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 4 {
+		t.Fatalf("expected 4 perf data items, got %d: %v", len(data), data)
+	}
+
+	if d := data[0]; d.Label != "time" || d.Value != 0.12 || d.UOM != "s" ||
+		d.Warn != "1" || d.Crit != "2" || *d.Min != 0 || *d.Max != 10 {
+		t.Errorf("unexpected perf data item: %+v", d)
+	}
+	if d := data[1]; d.Label != "used space" || d.Value != 80 || d.UOM != "%" ||
+		d.Min != nil || d.Max != nil {
+		t.Errorf("unexpected perf data item: %+v", d)
+	}
+	if d := data[2]; d.Label != "it's" || !d.Undetermined {
+		t.Errorf("unexpected perf data item: %+v", d)
+	}
+	if d := data[3]; d.Label != "count" || d.Value != 3 || d.UOM != "" {
+		t.Errorf("unexpected perf data item: %+v", d)
+	}
+
+	if _, err := parsePerfData("novalue"); err == nil {
+		t.Error("expected error for perf data without value")
+	}
+
+	data, err = parsePerfData("time=0.12s novalue load=x count=3")
+	if err == nil {
+		t.Error("expected error for malformed perf data items")
+	}
+	if expected := "time=0.12s count=3"; data.String() != expected {
+		t.Errorf("expected malformed items to be skipped, got %q", data.String())
+	}
+}
+
+func TestParsePluginOutput(t *testing.T) {
+	raw := "DISK OK | /=10MB;20;30 broken\nlong line 1\nlong line 2 | /var=5MB\n/tmp=1MB;;;0;100\n"
+
+	output, longOutput, data := parsePluginOutput(raw)
+	if expected := "DISK OK"; output != expected {
+		t.Errorf("expected output %q, got %q", expected, output)
+	}
+	if expected := "long line 1\nlong line 2"; longOutput != expected {
+		t.Errorf("expected long output %q, got %q", expected, longOutput)
+	}
+
+	if expected := "/=10MB;20;30 /var=5MB /tmp=1MB;;;0;100"; data.String() != expected {
+		t.Errorf("expected perf data %q, got %q", expected, data.String())
+	}
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Built-in TCP and UDP port check, replacing check_tcp and check_udp.
+type portCheck struct {
+	Host      string
+	Port      int
+	Protocol  string         `json:"Protocol,omitempty"`  // "tcp" (default) or "udp"
+	IPVersion int            `json:"IPVersion,omitempty"` // 4 or 6, default any
+	Send      string         `json:"Send,omitempty"`      // Payload to send after connecting
+	Expect    string         `json:"Expect,omitempty"`    // Regex the banner/response must match
+	WarnMS    int            `json:"WarnMS,omitempty"`    // Response time thresholds
+	CritMS    int            `json:"CritMS,omitempty"`
+	expect    *regexp.Regexp // Compiled Expect, set by validate
+}
+
+// Don't read more than this of a banner.
+const maxBannerSize = 4096
+
+func (p *portCheck) validate() error {
+	if p.Host == "" || p.Port <= 0 {
+		return errors.New("port check without Host or Port")
+	}
+	switch p.Protocol {
+	case "", "tcp", "udp":
+	default:
+		return fmt.Errorf("unknown protocol '%s'", p.Protocol)
+	}
+	switch p.IPVersion {
+	case 0, 4, 6:
+	default:
+		return fmt.Errorf("unknown IP version %d", p.IPVersion)
+	}
+	// Without a response, UDP can't tell whether the port is open, as
+	// sending to a closed port succeeds anyway.
+	if p.Protocol == "udp" && (p.Send == "" || p.Expect == "") {
+		return errors.New("UDP port check without Send or Expect, can't tell whether the port is open")
+	}
+	if p.Expect != "" {
+		regex, err := regexp.Compile(p.Expect)
+		if err != nil {
+			return err
+		}
+		p.expect = regex
+	}
+	return nil
+}
+
+// The compiled Expect regex, nil if none. Only compiled here if validate
+// wasn't called before.
+func (p *portCheck) expectRegexp() (*regexp.Regexp, error) {
+	if p.expect != nil || p.Expect == "" {
+		return p.expect, nil
+	}
+	return regexp.Compile(p.Expect)
+}
+
+func (p *portCheck) run(ctx context.Context) checkResult {
+	protocol := p.Protocol
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	network := protocol
+	if p.IPVersion != 0 {
+		network += strconv.Itoa(p.IPVersion)
+	}
+	label := strings.ToUpper(protocol)
+	address := net.JoinHostPort(p.Host, strconv.Itoa(p.Port))
+
+	expect, err := p.expectRegexp()
+	if err != nil {
+		return resultf(nagiosUnknown, "%s UNKNOWN: %v", label, err)
+	}
+
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return resultf(nagiosCritical, "%s CRITICAL: %v", label, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if p.Send != "" {
+		if _, err := conn.Write([]byte(p.Send)); err != nil {
+			return resultf(nagiosCritical, "%s CRITICAL: %v", label, err)
+		}
+	}
+
+	var banner string
+	if expect != nil {
+		if banner, err = readBanner(conn, expect); err != nil {
+			return resultf(nagiosCritical, "%s CRITICAL: %v", label, err)
+		}
+	}
+	elapsed := time.Since(start)
+
+	status := thresholdStatus(float64(elapsed.Milliseconds()), float64(p.WarnMS), float64(p.CritMS))
+	output := fmt.Sprintf("%s %s - %.3f second response time on %s", label, status.Str(),
+		elapsed.Seconds(), address)
+	if banner != "" {
+		output += fmt.Sprintf(" [%s]", strings.TrimSpace(strings.SplitN(banner, "\n", 2)[0]))
+	}
+
+	zero := 0.0
+	return checkResult{
+		status: status,
+		output: output,
+		perfData: perfData{{
+			Label: "time",
+			Value: elapsed.Seconds(),
+			UOM:   "s",
+			Warn:  formatThreshold(float64(p.WarnMS) / 1000),
+			Crit:  formatThreshold(float64(p.CritMS) / 1000),
+			Min:   &zero,
+		}},
+	}
+}
+
+// Read from the connection until the banner matches the regex.
+func readBanner(conn net.Conn, expect *regexp.Regexp) (string, error) {
+	var (
+		banner []byte
+		buf    = make([]byte, maxBannerSize)
+	)
+
+	for len(banner) < maxBannerSize {
+		n, err := conn.Read(buf[:maxBannerSize-len(banner)])
+		banner = append(banner, buf[:n]...)
+		if expect.Match(banner) {
+			return string(banner), nil
+		}
+		if err != nil {
+			if len(banner) == 0 {
+				return "", err
+			}
+			break
+		}
+	}
+
+	return "", fmt.Errorf("response '%s' doesn't match '%s'",
+		strings.TrimSpace(string(banner)), expect.String())
+}
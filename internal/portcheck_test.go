@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestPortCheck(t *testing.T) {
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcpListener.Close()
+	go func() {
+		for {
+			conn, err := tcpListener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+			conn.Close()
+		}
+	}()
+	tcpPort := tcpListener.Addr().(*net.TCPAddr).Port
+
+	udpConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udpConn.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := udpConn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			udpConn.WriteTo([]byte(strings.ToUpper(string(buf[:n]))), addr)
+		}
+	}()
+	udpPort := udpConn.LocalAddr().(*net.UDPAddr).Port
+
+	tests := []checkTest{
+		{
+			name:     "TCP banner",
+			check:    check{Port: &portCheck{Host: "127.0.0.1", Port: tcpPort, IPVersion: 4, Expect: "^SSH-2\\.0"}},
+			expected: nagiosOk,
+			output:   "[SSH-2.0-OpenSSH_9.6]",
+		},
+		{
+			name:     "TCP banner mismatch",
+			check:    check{Port: &portCheck{Host: "127.0.0.1", Port: tcpPort, Expect: "^220 "}},
+			expected: nagiosCritical,
+			output:   "doesn't match",
+		},
+		{
+			name:     "TCP connection refused",
+			check:    check{Port: &portCheck{Host: "127.0.0.1", Port: 1}},
+			expected: nagiosCritical,
+		},
+		{
+			name:     "UDP response",
+			check:    check{Port: &portCheck{Host: "127.0.0.1", Port: udpPort, Protocol: "udp", Send: "ping", Expect: "PING"}},
+			expected: nagiosOk,
+			output:   "UDP OK",
+		},
+	}
+
+	runCheckTests(t, tests)
+
+	if err := (&portCheck{Host: "127.0.0.1", Port: udpPort, Protocol: "udp"}).validate(); err == nil {
+		t.Error("expected UDP check without payload to be invalid")
+	}
+	if err := (&portCheck{Host: "127.0.0.1", Port: udpPort, Protocol: "udp", Send: "ping"}).validate(); err == nil {
+		t.Error("expected UDP check without expected response to be invalid")
+	}
+
+	invalid := portCheck{Host: "127.0.0.1", Port: 1, Expect: "(unclosed"}
+	if err := invalid.validate(); err == nil {
+		t.Error("expected an invalid Expect regex to be invalid")
+	}
+	expectResult(t, invalid.run(context.Background()), nagiosUnknown, "UNKNOWN: ")
+}
@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// Default message priorities per status. ntfy has priorities from 1 (min) to
+// 5 (urgent), Gotify from 0 to 10.
+var (
+	ntfyPriorities = map[string]int{
+		nagiosCritical.Str(): 5,
+		nagiosWarning.Str():  4,
+		nagiosUnknown.Str():  3,
+		nagiosOk.Str():       2,
+	}
+	gotifyPriorities = map[string]int{
+		nagiosCritical.Str(): 8,
+		nagiosWarning.Str():  5,
+		nagiosUnknown.Str():  4,
+		nagiosOk.Str():       2,
+	}
+)
+
+// A concise message of a push notifier, one per changed check.
+type pushMessage struct {
+	status  string
+	message string
+}
+
+// One message per changed check. Without changed checks (e.g. forced reports
+// or re-notifications), the subject summarizes the report, as the whole body
+// is too long for a push message. Only errors are sent as they are.
+func pushMessages(n notification) []pushMessage {
+	switch {
+	case n.Error:
+		return []pushMessage{{nagiosUnknown.Str(), n.Body}}
+	case len(n.Changes) == 0:
+		return []pushMessage{{nagiosUnknown.Str(), n.Subject}}
+	}
+
+	messages := make([]pushMessage, len(n.Changes))
+	for i, change := range n.Changes {
+		messages[i] = pushMessage{
+			status:  change.Status,
+			message: fmt.Sprintf("%s->%s: %s: %s", change.PrevStatus, change.Status, change.Name, change.Output),
+		}
+	}
+	return messages
+}
+
+// Configured priorities override the defaults per status.
+func pushPriority(status string, priorities, defaults map[string]int) int {
+	if priority, ok := priorities[status]; ok {
+		return priority
+	}
+	return defaults[status]
+}
+
+func validatePriorities(priorities, defaults map[string]int, minPriority, maxPriority int) error {
+	for status, priority := range priorities {
+		if _, ok := defaults[status]; !ok {
+			return fmt.Errorf("priority of unknown status '%s'", status)
+		}
+		if priority < minPriority || priority > maxPriority {
+			return fmt.Errorf("priority %d of %s not within %d and %d", priority, status, minPriority, maxPriority)
+		}
+	}
+	return nil
+}
+
+// Post each message and collect the failures.
+func pushAll(n notification, post func(pushMessage) error) error {
+	var errs []error
+	for _, message := range pushMessages(n) {
+		if err := post(message); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Sends push notifications via a ntfy server, see https://ntfy.sh.
+type ntfyNotifier struct {
+	Enable     bool
+	Topic      string
+	Server     string         `json:"Server,omitempty"`     // Default https://ntfy.sh
+	Token      string         `json:"Token,omitempty"`      // Access token, if required
+	Priorities map[string]int `json:"Priorities,omitempty"` // E.g. {"CRITICAL": 5}
+}
+
+func (n *ntfyNotifier) name() string {
+	return "ntfy"
+}
+
+func (n *ntfyNotifier) enabled() bool {
+	return n.Enable
+}
+
+func (n *ntfyNotifier) validate() error {
+	if n.Topic == "" {
+		return errors.New("ntfy notifier without Topic")
+	}
+	if err := validatePriorities(n.Priorities, ntfyPriorities, 1, 5); err != nil {
+		return fmt.Errorf("ntfy notifier: %w", err)
+	}
+	return nil
+}
+
+func (n *ntfyNotifier) notify(report notification) error {
+	server := n.Server
+	if server == "" {
+		server = "https://ntfy.sh"
+	}
+	// Publishing as JSON, as the title may contain characters not allowed
+	// in HTTP headers.
+	webhook := webhookNotifier{URL: server, Token: n.Token}
+
+	return pushAll(report, func(message pushMessage) error {
+		body, err := json.Marshal(map[string]any{
+			"topic":    n.Topic,
+			"title":    report.Subject,
+			"message":  message.message,
+			"priority": pushPriority(message.status, n.Priorities, ntfyPriorities),
+		})
+		if err != nil {
+			return err
+		}
+		return webhook.deliver(body)
+	})
+}
+
+// Sends push notifications via a Gotify server, see https://gotify.net.
+type gotifyNotifier struct {
+	Enable     bool
+	URL        string         // E.g. https://gotify.foo.zone
+	Token      string         // Application token
+	Priorities map[string]int `json:"Priorities,omitempty"` // E.g. {"CRITICAL": 8}
+}
+
+func (g *gotifyNotifier) name() string {
+	return "Gotify"
+}
+
+func (g *gotifyNotifier) enabled() bool {
+	return g.Enable
+}
+
+func (g *gotifyNotifier) validate() error {
+	if g.URL == "" || g.Token == "" {
+		return errors.New("Gotify notifier without URL or Token")
+	}
+	if err := validatePriorities(g.Priorities, gotifyPriorities, 0, 10); err != nil {
+		return fmt.Errorf("Gotify notifier: %w", err)
+	}
+	return nil
+}
+
+func (g *gotifyNotifier) notify(n notification) error {
+	endpoint, err := url.JoinPath(g.URL, "message")
+	if err != nil {
+		return err
+	}
+	webhook := webhookNotifier{URL: endpoint, Headers: map[string]string{"X-Gotify-Key": g.Token}}
+
+	return pushAll(n, func(message pushMessage) error {
+		body, err := json.Marshal(map[string]any{
+			"title":    n.Subject,
+			"message":  message.message,
+			"priority": pushPriority(message.status, g.Priorities, gotifyPriorities),
+		})
+		if err != nil {
+			return err
+		}
+		return webhook.deliver(body)
+	})
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPushNotifiers(t *testing.T) {
+	type message struct {
+		Path     string
+		Auth     string
+		Topic    string `json:"topic"`
+		Title    string `json:"title"`
+		Message  string `json:"message"`
+		Priority int    `json:"priority"`
+	}
+	var messages []message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := message{Path: r.URL.Path, Auth: r.Header.Get("Authorization") + r.Header.Get("X-Gotify-Key")}
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			t.Error(err)
+		}
+		messages = append(messages, m)
+	}))
+	defer server.Close()
+
+	n := notification{
+		Subject: "GOGIOS Report [C:1 W:0 U:0 S:0 OK:1]",
+		Body:    "This is the recent Gogios report!\n",
+		Changes: []checkChange{
+			{Name: "Check HTTP", Status: "CRITICAL", PrevStatus: "OK", Output: "HTTP CRITICAL"},
+			{Name: "Check Ping", Status: "OK", PrevStatus: "WARNING", Output: "PING OK"},
+		},
+	}
+
+	ntfy := ntfyNotifier{Enable: true, Server: server.URL, Topic: "alerts", Token: "tk_secret",
+		Priorities: map[string]int{"OK": 1}}
+	gotify := gotifyNotifier{Enable: true, URL: server.URL + "/", Token: "secret"}
+	for _, notifier := range []notifier{&ntfy, &gotify} {
+		if err := notifier.validate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := notifier.notify(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []message{
+		{"/", "Bearer tk_secret", "alerts", n.Subject, "OK->CRITICAL: Check HTTP: HTTP CRITICAL", 5},
+		{"/", "Bearer tk_secret", "alerts", n.Subject, "WARNING->OK: Check Ping: PING OK", 1},
+		{"/message", "secret", "", n.Subject, "OK->CRITICAL: Check HTTP: HTTP CRITICAL", 8},
+		{"/message", "secret", "", n.Subject, "WARNING->OK: Check Ping: PING OK", 2},
+	}
+	if len(messages) != len(expected) {
+		t.Fatalf("expected %d messages, got %+v", len(expected), messages)
+	}
+	for i := range expected {
+		if messages[i] != expected[i] {
+			t.Errorf("expected message %+v, got %+v", expected[i], messages[i])
+		}
+	}
+
+	// Errors are sent as they are, reports without changes only as their
+	// subject.
+	messages = nil
+	if err := gotify.notify(notification{Subject: "GOGIOS: An error occured", Body: "broken", Error: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].Message != "broken" || messages[0].Priority != 4 {
+		t.Errorf("unexpected error message: %+v", messages)
+	}
+	messages = nil
+	if err := gotify.notify(notification{Subject: n.Subject, Body: n.Body}); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].Message != n.Subject {
+		t.Errorf("unexpected report message: %+v", messages)
+	}
+
+	ntfy.Priorities = map[string]int{"CRITICAL": 6}
+	if err := ntfy.validate(); err == nil {
+		t.Error("expected an out of range priority to fail")
+	}
+}
@@ -17,6 +17,12 @@ func Run(ctx context.Context, configFile string, renotify, force bool) {
 		notifyError(conf, err)
 	}
 
+	lock := takeRunLock(ctx, conf)
+	if lock == nil {
+		return
+	}
+	defer lock.release()
+
 	state, err := newState(conf)
 	if err != nil {
 		notifyError(conf, err)
@@ -31,7 +37,7 @@ func Run(ctx context.Context, configFile string, renotify, force bool) {
 
 	subject, body, doNotify := state.report(renotify, force)
 	if doNotify {
-		if err := notify(conf, subject, body); err != nil {
+		if err := notify(conf, notification{Subject: subject, Body: body, Changes: state.changes()}); err != nil {
 			log.Println("error:", err)
 			return
 		}
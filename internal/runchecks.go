@@ -3,7 +3,6 @@ package internal
 import (
 	"context"
 	"log"
-	"math/rand"
 	"sync"
 	"time"
 )
@@ -13,12 +12,18 @@ func runChecks(ctx context.Context, state state, conf config) state {
 		limitCh  = make(chan struct{}, conf.CheckConcurrency)
 		inputCh  = make(chan namedCheck)
 		outputCh = make(chan checkResult)
-		deps     = newDependency(conf)
+		deps     = newDependency(conf, state)
+		invalid  = conf.invalidChecks()
+		sshPool  = newSSHPool()
 	)
+	defer sshPool.close()
+	conf.setSSHPool(sshPool)
 
 	go func() {
-		for name, check := range conf.Checks {
-			inputCh <- namedCheck{check, name}
+		// Start checks before their dependents, so they are first in
+		// line for the concurrency limit.
+		for _, name := range conf.topologicalOrder() {
+			inputCh <- namedCheck{conf.Checks[name], name}
 		}
 		close(inputCh)
 	}()
@@ -37,18 +42,21 @@ func runChecks(ctx context.Context, state state, conf config) state {
 	inputWg.Add(len(conf.Checks))
 
 	for check := range inputCh {
+		if err, ok := invalid[check.name]; ok {
+			// Resolved right away, so dependents don't wait until the
+			// timeout (e.g. in a dependency cycle).
+			deps.notOk(check.name, check.name)
+			outputCh <- check.invalid(err)
+			inputWg.Done()
+			continue
+		}
 		if age := state.age(check.name); check.RunInterval > int(age.Seconds()) {
-			lastCheckState, ok := state.checks[check.name]
-			if ok {
+			if lastCheckState, ok := state.checks[check.name]; ok {
 				log.Printf("Skipping %s: interval not yet reached (%v (%v) <= %v)", check.name,
 					int(age.Seconds()), age, check.RunInterval)
-				outputCh <- checkResult{
-					name:      check.name,
-					output:    lastCheckState.output,
-					epoch:     lastCheckState.Epoch,
-					status:    lastCheckState.Status,
-					federated: lastCheckState.federated,
-				}
+				// Dependents go with the last known status.
+				deps.resolve(check.name, lastCheckState.Status, lastCheckState.RootCause)
+				outputCh <- checkResult{name: check.name, cached: true}
 				inputWg.Done()
 				continue
 			}
@@ -75,39 +83,44 @@ func runChecks(ctx context.Context, state state, conf config) state {
 func runCheck(ctx context.Context, limitCh chan struct{}, deps dependency,
 	check namedCheck, conf config, retries int,
 ) checkResult {
-	if err := deps.wait(ctx, check.DependsOn); err != nil {
-		deps.notOk(check.name)
-		return check.skip(err.Error())
+	if rootCause, err := deps.wait(ctx, check.DependsOn); err != nil {
+		if rootCause == "" {
+			deps.notOk(check.name, check.name)
+			return check.skip(err.Error())
+		}
+		deps.notOk(check.name, rootCause)
+		return check.unreachable(rootCause, err.Error())
 	}
 
-	if check.RandomSpread > 0 {
-		d := time.Duration(rand.Intn(check.RandomSpread)) * time.Second
+	if d := spread(check); d > 0 {
 		log.Printf("Sleeping %v before running %s", d, check.name)
 		time.Sleep(d)
 	}
 
-	limitCh <- struct{}{}
-
-	checkCtx, cancel := context.WithTimeout(ctx,
-		time.Duration(conf.CheckTimeoutS)*time.Second)
-	defer cancel()
-
-	checkResult := check.run(checkCtx)
+	checkResult := execCheck(ctx, limitCh, check, conf)
 
 	if checkResult.status != nagiosOk && retries > 0 {
-		<-limitCh
 		retryDuration := time.Duration(check.RetryInterval) * time.Second
 		time.Sleep(retryDuration)
 		log.Printf("Retrying %s after %v", check.name, retryDuration)
 		return runCheck(ctx, limitCh, deps, check, conf, retries-1)
 	}
 
-	if checkResult.status == nagiosCritical {
-		deps.notOk(check.name)
-	} else {
-		deps.ok(check.name)
-	}
+	deps.resolve(check.name, checkResult.status, checkResult.rootCause)
 
-	<-limitCh
 	return checkResult
-}
\ No newline at end of file
+}
+
+// Execute a check once, honoring the concurrency limit and the check timeout.
+func execCheck(ctx context.Context, limitCh chan struct{}, check namedCheck,
+	conf config,
+) checkResult {
+	limitCh <- struct{}{}
+	defer func() { <-limitCh }()
+
+	checkCtx, cancel := context.WithTimeout(ctx,
+		time.Duration(conf.CheckTimeoutS)*time.Second)
+	defer cancel()
+
+	return check.run(checkCtx)
+}
@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunChecksSkippedDependency(t *testing.T) {
+	conf := config{
+		CheckTimeoutS:    5,
+		CheckConcurrency: 2,
+		Federated:        []string{"http://example.org"},
+		Checks: map[string]check{
+			"Parent OK":       {Plugin: "true", RunInterval: 3600},
+			"Parent Critical": {Plugin: "true", RunInterval: 3600},
+			"Child 1":         {Plugin: "true", DependsOn: []string{"Parent OK"}},
+			"Child 2":         {Plugin: "true", DependsOn: []string{"Parent Critical"}},
+			"Child 3":         {Plugin: "true", DependsOn: []string{"Federated Check"}},
+			"Child 4":         {Plugin: "true", DependsOn: []string{"Missing Check"}},
+		},
+	}
+
+	state := state{checks: map[string]checkState{
+		"Parent OK":       {Status: nagiosOk, Epoch: time.Now().Unix()},
+		"Parent Critical": {Status: nagiosCritical, Epoch: time.Now().Unix()},
+		"Federated Check": {Status: nagiosCritical, Federated: "http://example.org"},
+	}}
+
+	// Dependents must not wait for the skipped checks until the timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	state = runChecks(ctx, state, conf)
+
+	for name, expected := range map[string]nagiosCode{
+		"Parent OK":       nagiosOk,
+		"Parent Critical": nagiosCritical,
+		"Child 1":         nagiosOk,
+		"Child 2":         nagiosUnreachable,
+		"Child 3":         nagiosUnreachable,
+		"Child 4":         nagiosOk,
+	} {
+		if cs := state.checks[name]; cs.Status != expected {
+			t.Errorf("expected %s to be %s, got %s: %s", name, expected.Str(), cs.Status.Str(), cs.Output)
+		}
+	}
+	if ctx.Err() != nil {
+		t.Error("expected checks to complete before the timeout")
+	}
+	if !strings.Contains(logs.String(), "check 'Child 4' depends on 'Missing Check', which is neither") {
+		t.Errorf("expected a warning about the unknown dependency, got %q", logs.String())
+	}
+}
+
+func TestRunChecksInvalid(t *testing.T) {
+	conf := config{
+		CheckTimeoutS:    5,
+		CheckConcurrency: 2,
+		Checks: map[string]check{
+			"A":       {Plugin: "true", DependsOn: []string{"B"}},
+			"B":       {Plugin: "true", DependsOn: []string{"A"}},
+			"Self":    {Plugin: "true", DependsOn: []string{"Self"}},
+			"Child":   {Plugin: "true", DependsOn: []string{"A"}},
+			"Broken":  {HTTP: &httpCheck{URL: "https://example.org", BodyRegex: "("}},
+			"Missing": {Plugin: "true", DependsOn: []string{"Missing Check"}},
+			"Other":   {Plugin: "true"},
+		},
+	}
+
+	// Only the invalid checks aren't run. Neither the cycle members nor
+	// their dependents may wait until the timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	state := runChecks(ctx, state{checks: make(map[string]checkState)}, conf)
+
+	for name, expected := range map[string]nagiosCode{
+		"A":       nagiosUnknown,
+		"B":       nagiosUnknown,
+		"Self":    nagiosUnknown,
+		"Child":   nagiosUnreachable,
+		"Broken":  nagiosUnknown,
+		"Missing": nagiosUnknown,
+		"Other":   nagiosOk,
+	} {
+		if cs := state.checks[name]; cs.Status != expected {
+			t.Errorf("expected %s to be %s, got %s: %s", name, expected.Str(), cs.Status.Str(), cs.Output)
+		}
+	}
+	if ctx.Err() != nil {
+		t.Error("expected checks to complete before the timeout")
+	}
+	if output := state.checks["Broken"].Output; !strings.HasPrefix(output, "invalid check config, not run: check 'Broken': ") {
+		t.Errorf("unexpected output of the invalid check: %s", output)
+	}
+}
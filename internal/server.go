@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Serve the HTTP endpoints (e.g. heartbeat pings) on the ListenAddr until the
+// context is done. For setups running the checks via CRON.
+func Serve(ctx context.Context, configFile string) {
+	conf, err := newConfig(configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := conf.sanityCheck(); err != nil {
+		log.Println("error:", err)
+	}
+	if conf.ListenAddr == "" {
+		log.Fatal("no ListenAddr configured")
+	}
+	if err := serveHTTP(ctx, conf, conf.httpHandler()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Don't accept larger submitted check results.
+const maxSubmissionSize = 1 << 20
+
+func serveHTTP(ctx context.Context, conf config, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              conf.ListenAddr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Println("Listening on", conf.ListenAddr)
+	var err error
+	if conf.ListenCertFile != "" {
+		err = server.ListenAndServeTLS(conf.ListenCertFile, conf.ListenKeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (conf config) httpHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ping/{name...}", conf.handlePing)
+	mux.HandleFunc("POST /ping/{name...}", conf.handlePing)
+	mux.HandleFunc("POST /submit", conf.handleSubmit)
+	return conf.authenticate(mux)
+}
+
+// Require the ListenToken as a bearer token, if configured.
+func (conf config) authenticate(next http.Handler) http.Handler {
+	if conf.ListenToken == "" {
+		return next
+	}
+	expected := []byte("Bearer " + conf.ListenToken)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (conf config) handlePing(w http.ResponseWriter, r *http.Request) {
+	heartbeat, err := conf.heartbeat(r.PathValue("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := heartbeat.ping(); err != nil {
+		log.Println("error:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "OK")
+}
+
+func (conf config) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	// Otherwise, anyone reaching the endpoint could change the status of
+	// the passive checks.
+	if conf.ListenToken == "" {
+		http.Error(w, "submitting results requires a ListenToken", http.StatusForbidden)
+		return
+	}
+	var sub submission
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubmissionSize)).Decode(&sub); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := conf.submit(sub); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fmt.Fprintln(w, "OK")
+}
@@ -0,0 +1,243 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Run the Plugin of a check on a remote host via SSH, replacing check_by_ssh.
+type sshOptions struct {
+	Host       string // host[:port], default port 22
+	User       string
+	Key        string // Private key file, without passphrase
+	KnownHosts string `json:"KnownHosts,omitempty"` // Default ~/.ssh/known_hosts
+	pool       *sshPool
+}
+
+// SSH connections shared by all checks connecting to the same host as the same
+// user. A pool is owned by a run (or the daemon or agent), which closes it at
+// its end.
+type sshPool struct {
+	mu    sync.Mutex
+	hosts map[string]*sshHost
+}
+
+type sshHost struct {
+	mu     sync.Mutex // Only one connection attempt per host at once
+	client *ssh.Client
+}
+
+func newSSHPool() *sshPool {
+	return &sshPool{hosts: make(map[string]*sshHost)}
+}
+
+// Let all SSH checks of the config share the connections of the pool.
+func (conf config) setSSHPool(pool *sshPool) {
+	for _, check := range conf.Checks {
+		if check.SSH != nil {
+			check.SSH.pool = pool
+		}
+	}
+}
+
+func (o *sshOptions) validate() error {
+	if o.Host == "" || o.User == "" || o.Key == "" {
+		return errors.New("SSH without Host, User or Key")
+	}
+	return nil
+}
+
+func (o *sshOptions) address() string {
+	if _, _, err := net.SplitHostPort(o.Host); err != nil {
+		return net.JoinHostPort(o.Host, "22")
+	}
+	return o.Host
+}
+
+func (c check) runSSH(ctx context.Context, name string) checkResult {
+	sshFailed := func(err error) checkResult {
+		return checkResult{
+			name:   name,
+			output: fmt.Sprintf("SSH UNKNOWN: %s@%s: %v", c.SSH.User, c.SSH.Host, err),
+			epoch:  time.Now().Unix(),
+			status: nagiosUnknown,
+		}
+	}
+
+	pool := c.SSH.pool
+	if pool == nil {
+		// Not sharing the connection with other checks.
+		pool = newSSHPool()
+		defer pool.close()
+	}
+
+	session, err := pool.session(ctx, c.SSH)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return timedOut(name)
+		}
+		return sshFailed(err)
+	}
+	defer session.Close()
+
+	// Unlike os/exec, the session copies stdout and stderr concurrently.
+	var output lockedBuffer
+	session.Stdout = &output
+	session.Stderr = &output
+
+	done := make(chan error, 1)
+	go func() { done <- session.Run(shellQuote(append([]string{c.Plugin}, c.Args...))) }()
+
+	select {
+	case <-ctx.Done():
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		return timedOut(name)
+	case err = <-done:
+	}
+
+	var exitErr *ssh.ExitError
+	switch {
+	case err == nil:
+		return pluginResult(name, output.String(), int(nagiosOk))
+	case errors.As(err, &exitErr):
+		return pluginResult(name, output.String(), exitErr.ExitStatus())
+	default:
+		// E.g. the connection broke or the remote command was killed by
+		// a signal, there is no exit code.
+		return sshFailed(err)
+	}
+}
+
+// Open a session on a shared connection. A connection broken since the
+// previous session (e.g. in daemon mode) is reconnected once.
+func (p *sshPool) session(ctx context.Context, o *sshOptions) (*ssh.Session, error) {
+	key := o.User + "@" + o.address() + " " + o.Key
+	p.mu.Lock()
+	host, ok := p.hosts[key]
+	if !ok {
+		host = &sshHost{}
+		p.hosts[key] = host
+	}
+	p.mu.Unlock()
+
+	host.mu.Lock()
+	defer host.mu.Unlock()
+
+	if host.client != nil {
+		// A rejected session (e.g. too many of them) doesn't mean that
+		// the connection is broken.
+		session, err := host.client.NewSession()
+		var openErr *ssh.OpenChannelError
+		if err == nil || errors.As(err, &openErr) {
+			return session, err
+		}
+		host.client.Close()
+		host.client = nil
+	}
+
+	client, err := o.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	host.client = client
+	return client.NewSession()
+}
+
+func (o *sshOptions) dial(ctx context.Context) (*ssh.Client, error) {
+	keyBytes, err := os.ReadFile(o.Key)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", o.Key, err)
+	}
+
+	knownHostsFile := o.KnownHosts
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", o.address())
+	if err != nil {
+		return nil, err
+	}
+	// The handshake doesn't take a context.
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, o.address(), &ssh.ClientConfig{
+		User:            o.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+func (p *sshPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, host := range p.hosts {
+		host.mu.Lock()
+		if host.client != nil {
+			host.client.Close()
+		}
+		host.mu.Unlock()
+		delete(p.hosts, key)
+	}
+}
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// Quote every word for the remote shell.
+func shellQuote(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Serve SSH sessions running fake plugins, counting the connections.
+func serveSSH(t *testing.T, authorized ssh.PublicKey) (string, ssh.PublicKey, *atomic.Int32) {
+	t.Helper()
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(authorized.Marshal()) {
+				return nil, net.ErrClosed
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	var connections atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				connections.Add(1)
+				go ssh.DiscardRequests(reqs)
+				for newChannel := range chans {
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						continue
+					}
+					go serveSSHSession(channel, requests)
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), hostSigner.PublicKey(), &connections
+}
+
+func serveSSHSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	for req := range requests {
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(true, nil)
+
+		command := string(req.Payload[4:])
+		exitStatus := uint32(0)
+		switch command {
+		case "'/usr/lib/nagios/plugins/check_load' '-w' '5'":
+			channel.Write([]byte("OK - load average: 0.10|load1=0.1;5;10;0\n"))
+		case "'/usr/lib/nagios/plugins/check_disk' 'it'\\''s'":
+			channel.Write([]byte("DISK CRITICAL - / 95% used\n"))
+			exitStatus = 2
+		default:
+			channel.Stderr().Write([]byte("sh: " + command + ": not found\n"))
+			exitStatus = 127
+		}
+		channel.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, exitStatus))
+		return
+	}
+}
+
+func TestSSH(t *testing.T) {
+	dir := t.TempDir()
+
+	_, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBlock, err := ssh.MarshalPrivateKey(clientKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(keyBlock), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	clientSigner, err := ssh.NewSignerFromKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address, hostKey, connections := serveSSH(t, clientSigner.PublicKey())
+	knownHosts := filepath.Join(dir, "known_hosts")
+	if err := os.WriteFile(knownHosts, []byte(knownhosts.Line([]string{address}, hostKey)+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	otherKnownHosts := filepath.Join(dir, "other_known_hosts")
+	if err := os.WriteFile(otherKnownHosts, []byte(knownhosts.Line([]string{address}, clientSigner.PublicKey())+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	options := sshOptions{Host: address, User: "gogios", Key: keyFile, KnownHosts: knownHosts, pool: newSSHPool()}
+	defer options.pool.close()
+	tests := []checkTest{
+		{
+			name:     "OK",
+			check:    check{Plugin: "/usr/lib/nagios/plugins/check_load", Args: []string{"-w", "5"}, SSH: &options},
+			expected: nagiosOk,
+			output:   "OK - load average: 0.10",
+		},
+		{
+			name:     "quoted arguments",
+			check:    check{Plugin: "/usr/lib/nagios/plugins/check_disk", Args: []string{"it's"}, SSH: &options},
+			expected: nagiosCritical,
+			output:   "DISK CRITICAL - / 95% used",
+		},
+		{
+			name:     "plugin not found",
+			check:    check{Plugin: "/usr/lib/nagios/plugins/check_foo", SSH: &options},
+			expected: nagiosUnknown,
+			output:   "not found",
+		},
+	}
+
+	runCheckTests(t, tests)
+
+	if n := connections.Load(); n != 1 {
+		t.Errorf("expected the connection to be reused, got %d connections", n)
+	}
+
+	// Connection failures are distinct from plugin failures.
+	options.pool.close()
+	for _, options := range []sshOptions{
+		{Host: address, User: "gogios", Key: keyFile, KnownHosts: otherKnownHosts},
+		{Host: "127.0.0.1:1", User: "gogios", Key: keyFile, KnownHosts: knownHosts},
+	} {
+		result := check{Plugin: "/usr/lib/nagios/plugins/check_load", SSH: &options}.run(context.Background(), "SSH")
+		if result.status != nagiosUnknown || !strings.HasPrefix(result.output, "SSH UNKNOWN: gogios@") {
+			t.Errorf("expected an SSH failure, got %s: %s", result.status.Str(), result.output)
+		}
+	}
+
+	if err := (check{HTTP: &httpCheck{URL: "http://foo.zone"}, SSH: &options}).validate(); err == nil {
+		t.Error("expected SSH with a built-in check type to be invalid")
+	}
+}
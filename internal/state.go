@@ -7,20 +7,49 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 )
 
+// Version of the on-disk state format. Version 1 was a bare JSON map of check
+// names to check states.
+const stateVersion = 3
+
+type stateFile struct {
+	Version int
+	Checks  map[string]checkState
+}
+
 type checkState struct {
-	Status     nagiosCode
-	PrevStatus nagiosCode
-	Epoch      int64 `json:"Epoch,omitempty"`
-	output     string
-	federated  bool
+	Status       nagiosCode
+	PrevStatus   nagiosCode
+	HardStatus   nagiosCode
+	Epoch        int64    `json:"Epoch,omitempty"`
+	Output       string   `json:"Output,omitempty"`
+	LongOutput   string   `json:"LongOutput,omitempty"`
+	Federated    string   `json:"Federated,omitempty"`    // Origin endpoint
+	Passive      bool     `json:"Passive,omitempty"`      // Submitted, see passiveCheck
+	FirstFailure int64    `json:"FirstFailure,omitempty"` // Epoch of first non-OK result
+	Attempts     int      `json:"Attempts,omitempty"`     // Consecutive non-OK results
+	MaxAttempts  int      `json:"MaxAttempts,omitempty"`
+	Soft         bool     `json:"Soft,omitempty"`      // Not yet failed MaxAttempts times
+	RootCause    string   `json:"RootCause,omitempty"` // Failed dependency if unreachable
+	PerfData     perfData `json:"PerfData,omitempty"`
+
+	History            []nagiosCode `json:"History,omitempty"` // Recent results for flap detection
+	PercentStateChange float64      `json:"PercentStateChange,omitempty"`
+	Flapping           bool         `json:"Flapping,omitempty"`
+	flapChanged        bool         // Started or stopped flapping in this run
 }
 
+// PrevStatus is the previous hard status, so a soft state is never a change.
+// Changes of flapping checks are suppressed until they stopped flapping. An
+// unreachable check becoming OK again isn't a change either, as only the
+// recovery of its root cause is.
 func (cs checkState) changed() bool {
-	return cs.Status != cs.PrevStatus
+	return !cs.Soft && !cs.Flapping && cs.Status != cs.PrevStatus &&
+		!(cs.PrevStatus == nagiosUnreachable && cs.Status == nagiosOk)
 }
 
 type state struct {
@@ -52,15 +81,19 @@ func newState(conf config) (state, error) {
 		return s, err
 	}
 
-	if err := json.Unmarshal(bytes, &s.checks); err != nil {
+	if s.checks, err = decodeCheckStates(bytes); err != nil {
 		return s, err
 	}
 
 	var obsolete []string
-	for name := range s.checks {
-		if _, ok := conf.Checks[name]; !ok {
-			obsolete = append(obsolete, name)
+	for name, cs := range s.checks {
+		if _, ok := conf.Checks[name]; ok {
+			continue
+		}
+		if cs.Federated != "" && slices.Contains(conf.Federated, cs.Federated) {
+			continue // Replaced or removed by mergeFederated
 		}
+		obsolete = append(obsolete, name)
 	}
 
 	for _, name := range obsolete {
@@ -72,13 +105,51 @@ func newState(conf config) (state, error) {
 }
 
 func (s state) update(result checkResult) {
-	prevStatus := nagiosUnknown
 	prevState, ok := s.checks[result.name]
+	// A passive check without a new submission isn't another attempt.
+	if ok && (result.cached || result.passive && result.epoch == prevState.Epoch) {
+		// Not re-checked, so the status didn't change since the last run.
+		prevState.PrevStatus = prevState.HardStatus
+		prevState.flapChanged = false
+		s.checks[result.name] = prevState
+		return
+	}
+
+	cs := checkState{
+		Status:      result.status,
+		PrevStatus:  nagiosUnknown,
+		Epoch:       result.epoch,
+		Output:      result.output,
+		LongOutput:  result.longOutput,
+		Federated:   result.federated,
+		Passive:     result.passive,
+		MaxAttempts: result.maxAttempts,
+		RootCause:   result.rootCause,
+		PerfData:    result.perfData,
+	}
 	if ok {
-		prevStatus = prevState.Status
+		cs.PrevStatus = prevState.HardStatus
+	}
+
+	if cs.Status != nagiosOk {
+		cs.Attempts = 1
+		cs.FirstFailure = result.epoch
+		if ok && prevState.Status != nagiosOk && prevState.Attempts > 0 {
+			cs.Attempts = prevState.Attempts + 1
+			cs.FirstFailure = prevState.FirstFailure
+		}
 	}
 
-	cs := checkState{result.status, prevStatus, result.epoch, result.output, result.federated}
+	// Like Nagios, only a change from OK to non-OK goes through soft states.
+	// Recoveries and changes between non-OK states are hard immediately.
+	cs.Soft = cs.Status != nagiosOk && cs.PrevStatus == nagiosOk &&
+		cs.Attempts < cs.MaxAttempts
+	cs.HardStatus = cs.Status
+	if cs.Soft {
+		cs.HardStatus = cs.PrevStatus
+	}
+	cs.updateFlapping(prevState, result.flapLow, result.flapHigh)
+
 	s.checks[result.name] = cs
 	log.Println(result.name, cs)
 }
@@ -91,23 +162,109 @@ func (s state) age(name string) time.Duration {
 	return time.Duration(0)
 }
 
-// To be used to merge the state of another server running Gogios
-func (s state) merge(other state) error {
+// To be used to merge the state of another server running Gogios. States
+// merged from the same origin before are replaced, and those not returned
+// again (e.g. removed on the other server) are dropped, except for keep.
+func (s state) merge(other state, origin, keep string) error {
 	for name, cs := range other.checks {
-		if _, ok := s.checks[name]; ok {
+		if cs.Federated == "" {
+			cs.Federated = origin
+			other.checks[name] = cs
+		}
+		if prevState, ok := s.checks[name]; ok && prevState.Federated != cs.Federated {
 			return fmt.Errorf("can't merge state due to duplicate check name '%s'", name)
 		}
+	}
+
+	for name, cs := range s.checks {
+		if _, ok := other.checks[name]; !ok && cs.Federated == origin && name != keep {
+			delete(s.checks, name)
+			log.Printf("State of %s is obsolete (removed)", name)
+		}
+	}
+
+	for name, cs := range other.checks {
+		// The other server notified (or, as a daemon, acknowledged) its
+		// changes on its own, so the previous status is the one merged
+		// before, like for local checks.
+		cs.PrevStatus = nagiosUnknown
+		if prevState, ok := s.checks[name]; ok {
+			cs.PrevStatus = prevState.HardStatus
+		}
 		s.checks[name] = cs
 	}
 	return nil
 }
 
-func (s state) mergeFromBytes(bytes []byte) error {
-	var other state
-	if err := json.Unmarshal(bytes, &other.checks); err != nil {
+// Mark all status changes as notified, so they won't be reported as changed
+// again. Used by the daemon, which notifies as results arrive.
+func (s state) acknowledge() {
+	for name, cs := range s.checks {
+		cs.PrevStatus = cs.HardStatus
+		cs.flapChanged = false
+		s.checks[name] = cs
+	}
+}
+
+func (s state) mergeFromBytes(bytes []byte, origin, keep string) error {
+	var (
+		other state
+		err   error
+	)
+	if other.checks, err = decodeCheckStates(bytes); err != nil {
 		return err
 	}
-	return s.merge(other)
+	return s.merge(other, origin, keep)
+}
+
+// Decode check states of any known state file version.
+func decodeCheckStates(bytes []byte) (map[string]checkState, error) {
+	var sf stateFile
+	if err := json.Unmarshal(bytes, &sf); err != nil || sf.Version == 0 {
+		// No version, so it must be version 1.
+		sf.Version = 1
+		sf.Checks = make(map[string]checkState)
+		if err := json.Unmarshal(bytes, &sf.Checks); err != nil {
+			return nil, err
+		}
+	}
+
+	if sf.Version > stateVersion {
+		return nil, fmt.Errorf("unsupported state version %d (expected <= %d)",
+			sf.Version, stateVersion)
+	}
+	if sf.Checks == nil {
+		sf.Checks = make(map[string]checkState)
+	}
+
+	if sf.Version < 2 {
+		migrateV1(sf.Checks)
+	}
+	if sf.Version < 3 {
+		migrateV2(sf.Checks)
+	}
+
+	return sf.Checks, nil
+}
+
+// Version 1 didn't keep track of failures, so treat every non-OK check as
+// failing since its last check.
+func migrateV1(checks map[string]checkState) {
+	for name, cs := range checks {
+		if cs.Status != nagiosOk && cs.Attempts == 0 {
+			cs.Attempts = 1
+			cs.FirstFailure = cs.Epoch
+			checks[name] = cs
+		}
+	}
+}
+
+// Version 2 didn't have soft states, so every status was a hard one.
+func migrateV2(checks map[string]checkState) {
+	for name, cs := range checks {
+		cs.HardStatus = cs.Status
+		checks[name] = cs
+	}
 }
 
 func (s state) persist() error {
@@ -118,7 +275,7 @@ func (s state) persist() error {
 		}
 	}
 
-	jsonData, err := json.Marshal(s.checks)
+	jsonData, err := json.Marshal(stateFile{Version: stateVersion, Checks: s.checks})
 	if err != nil {
 		return err
 	}
@@ -144,6 +301,12 @@ func (s state) report(renotify, force bool) (string, string, bool) {
 		sb.WriteString("There are no unhandled alerts...\n\n")
 	}
 
+	sb.WriteString("# Flapping alerts:\n\n")
+	numFlapping, flapChanged := s.reportFlapping(&sb)
+	if numFlapping == 0 {
+		sb.WriteString("There are no flapping alerts...\n\n")
+	}
+
 	sb.WriteString("# Stale alerts:\n\n")
 	numStale := s.reportStaleAlerts(&sb)
 	if numStale == 0 {
@@ -155,7 +318,7 @@ func (s state) report(renotify, force bool) (string, string, bool) {
 	subject := fmt.Sprintf("GOGIOS Report [C:%d W:%d U:%d S:%d OK:%d]",
 		numCriticals, numWarnings, numUnknown, numStale, numOK)
 
-	doNotify := force || (changed || (renotify && hasUnhandled))
+	doNotify := force || (changed || flapChanged || (renotify && hasUnhandled))
 	return subject, sb.String(), doNotify
 }
 
@@ -187,12 +350,53 @@ func (s state) reportChanged(sb *strings.Builder) (changed bool) {
 	return
 }
 
+// A check whose (hard) status changed, as passed to the notifiers.
+type checkChange struct {
+	Name       string
+	Status     string
+	PrevStatus string
+	Output     string
+	LongOutput string `json:"LongOutput,omitempty"`
+	PerfData   string `json:"PerfData,omitempty"`
+	Federated  string `json:"Federated,omitempty"` // Origin endpoint
+	Passive    bool   `json:"Passive,omitempty"`
+	Epoch      int64
+}
+
+// The checks reported as status changed, the most severe ones first.
+func (s state) changes() []checkChange {
+	var changes []checkChange
+	for _, status := range []nagiosCode{nagiosCritical, nagiosWarning, nagiosUnknown, nagiosOk} {
+		var names []string
+		for name, cs := range s.checks {
+			if cs.Status == status && cs.changed() && cs.Epoch >= s.staleEpoch {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+
+		for _, name := range names {
+			cs := s.checks[name]
+			changes = append(changes, checkChange{
+				Name:       name,
+				Status:     cs.Status.Str(),
+				PrevStatus: cs.PrevStatus.Str(),
+				Output:     cs.Output,
+				LongOutput: cs.LongOutput,
+				PerfData:   cs.PerfData.String(),
+				Federated:  cs.Federated,
+				Passive:    cs.Passive,
+				Epoch:      cs.Epoch,
+			})
+		}
+	}
+	return changes
+}
+
 func (s state) reportUnhandled(sb *strings.Builder) (numCriticals, numWarnings,
 	numUnknown, numOK int,
 ) {
-	numCriticals = s.reportBy(sb, false, false, func(cs checkState) bool {
-		return cs.Status == nagiosCritical
-	})
+	numCriticals = s.reportCriticals(sb)
 
 	numWarnings = s.reportBy(sb, false, false, func(cs checkState) bool {
 		return cs.Status == nagiosWarning
@@ -209,6 +413,53 @@ func (s state) reportUnhandled(sb *strings.Builder) (numCriticals, numWarnings,
 	return
 }
 
+// Report critical checks, each followed by the checks which are unreachable
+// because of it. Unreachable checks aren't counted, as they aren't the cause.
+func (s state) reportCriticals(sb *strings.Builder) (count int) {
+	unreachable := make(map[string][]string)
+	for name, cs := range s.checks {
+		if cs.Status == nagiosUnreachable && cs.Epoch >= s.staleEpoch {
+			unreachable[cs.RootCause] = append(unreachable[cs.RootCause], name)
+		}
+	}
+
+	for name, cs := range s.checks {
+		if cs.Status != nagiosCritical || cs.Epoch < s.staleEpoch {
+			continue
+		}
+		count++
+		s.writeLine(sb, name, cs, false, false)
+		for _, child := range unreachable[name] {
+			sb.WriteString("  ")
+			s.writeLine(sb, child, s.checks[child], false, false)
+		}
+		delete(unreachable, name)
+	}
+
+	// The root cause isn't critical anymore, but the unreachable checks
+	// haven't been checked again yet.
+	for _, children := range unreachable {
+		for _, child := range children {
+			s.writeLine(sb, child, s.checks[child], false, false)
+		}
+	}
+
+	if count > 0 || len(unreachable) > 0 {
+		sb.WriteString("\n")
+	}
+	return
+}
+
+func (s state) reportFlapping(sb *strings.Builder) (numFlapping int, flapChanged bool) {
+	numFlapping = s.reportBy(sb, false, false, func(cs checkState) bool {
+		if cs.flapChanged {
+			flapChanged = true
+		}
+		return cs.Flapping || cs.flapChanged
+	})
+	return
+}
+
 func (s state) reportStaleAlerts(sb *strings.Builder) int {
 	return s.reportBy(sb, false, true, func(cs checkState) bool {
 		return cs.Epoch < s.staleEpoch
@@ -226,27 +477,7 @@ func (s state) reportBy(sb *strings.Builder, showStatusChange, isStaleReport boo
 			continue // skip stale checks in non-stale report
 		}
 		count++
-
-		if showStatusChange && cs.changed() {
-			sb.WriteString(nagiosCode(cs.PrevStatus).Str())
-			sb.WriteString("->")
-		}
-
-		sb.WriteString(nagiosCode(cs.Status).Str())
-		sb.WriteString(": ")
-		sb.WriteString(name)
-		sb.WriteString(": ")
-		sb.WriteString(cs.output)
-		if cs.federated {
-			sb.WriteString(" [federated]")
-		}
-
-		if isStaleReport {
-			lastCheckedAgo := time.Since(time.Unix(cs.Epoch, 0))
-			sb.WriteString(fmt.Sprintf(" (last checked %v ago)", lastCheckedAgo))
-		}
-
-		sb.WriteString("\n")
+		s.writeLine(sb, name, cs, showStatusChange, isStaleReport)
 	}
 
 	if count > 0 {
@@ -255,6 +486,45 @@ func (s state) reportBy(sb *strings.Builder, showStatusChange, isStaleReport boo
 	return
 }
 
+func (s state) writeLine(sb *strings.Builder, name string, cs checkState,
+	showStatusChange, isStaleReport bool,
+) {
+	if showStatusChange && cs.changed() {
+		sb.WriteString(nagiosCode(cs.PrevStatus).Str())
+		sb.WriteString("->")
+	}
+
+	sb.WriteString(nagiosCode(cs.Status).Str())
+	if cs.Soft {
+		sb.WriteString(fmt.Sprintf(" (soft %d/%d)", cs.Attempts, cs.MaxAttempts))
+	}
+	sb.WriteString(": ")
+	sb.WriteString(name)
+	sb.WriteString(": ")
+	sb.WriteString(cs.Output)
+	switch {
+	case cs.Passive:
+		sb.WriteString(" [passive]")
+	case cs.Federated != "":
+		sb.WriteString(" [federated]")
+	}
+	switch {
+	case cs.Flapping && cs.flapChanged:
+		sb.WriteString(fmt.Sprintf(" (started flapping, %.1f%% state change)", cs.PercentStateChange))
+	case cs.Flapping:
+		sb.WriteString(fmt.Sprintf(" (flapping, %.1f%% state change)", cs.PercentStateChange))
+	case cs.flapChanged:
+		sb.WriteString(fmt.Sprintf(" (stopped flapping, %.1f%% state change)", cs.PercentStateChange))
+	}
+
+	if isStaleReport {
+		lastCheckedAgo := time.Since(time.Unix(cs.Epoch, 0))
+		sb.WriteString(fmt.Sprintf(" (last checked %v ago)", lastCheckedAgo))
+	}
+
+	sb.WriteString("\n")
+}
+
 func (s state) countBy(filter func(cs checkState) bool) (count int) {
 	for _, cs := range s.checks {
 		if filter(cs) {
@@ -262,4 +532,4 @@ func (s state) countBy(filter func(cs checkState) bool) (count int) {
 		}
 	}
 	return
-}
\ No newline at end of file
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -22,3 +23,136 @@ func TestAge(t *testing.T) {
 		t.Errorf("expected age < %v, got %v", maxAge, reportedAge)
 	}
 }
+
+func TestDecodeCheckStates(t *testing.T) {
+	v1 := []byte(`{"Check Foo":{"Status":2,"PrevStatus":0,"Epoch":42}}`)
+	checks, err := decodeCheckStates(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs := checks["Check Foo"]; cs.Status != nagiosCritical || cs.Attempts != 1 || cs.FirstFailure != 42 {
+		t.Errorf("unexpected migrated check state: %+v", cs)
+	}
+
+	v2 := []byte(`{"Version":2,"Checks":{"Check Bar":{"Status":1,"Output":"foo","Federated":"http://example.org"}}}`)
+	checks, err = decodeCheckStates(v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs := checks["Check Bar"]; cs.Output != "foo" || cs.Federated != "http://example.org" {
+		t.Errorf("unexpected check state: %+v", cs)
+	}
+
+	if _, err := decodeCheckStates([]byte(`{"Version":99,"Checks":{}}`)); err == nil {
+		t.Error("expected error for unsupported state version")
+	}
+}
+
+func TestPersistState(t *testing.T) {
+	conf := config{
+		StateDir: t.TempDir(),
+		Checks:   map[string]check{"Check Foo": {}},
+	}
+
+	s, err := newState(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.update(checkResult{name: "Check Foo", output: "out", longOutput: "long", status: nagiosWarning, epoch: 1})
+	s.update(checkResult{name: "Check Foo", output: "out", status: nagiosCritical, epoch: 2})
+	if err := s.persist(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = newState(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs := s.checks["Check Foo"]
+	if cs.Output != "out" || cs.Status != nagiosCritical || cs.PrevStatus != nagiosWarning {
+		t.Errorf("unexpected check state: %+v", cs)
+	}
+	if cs.Attempts != 2 || cs.FirstFailure != 1 {
+		t.Errorf("expected 2 attempts since epoch 1, got %+v", cs)
+	}
+}
+
+func TestSoftHardStates(t *testing.T) {
+	s := state{checks: make(map[string]checkState)}
+	result := checkResult{name: "Check Foo", status: nagiosOk, maxAttempts: 3}
+
+	s.update(result)
+	result.status = nagiosCritical
+	for attempt := 1; attempt < 3; attempt++ {
+		s.update(result)
+		cs := s.checks["Check Foo"]
+		if !cs.Soft || cs.Attempts != attempt || cs.HardStatus != nagiosOk || cs.changed() {
+			t.Errorf("expected soft state at attempt %d, got %+v", attempt, cs)
+		}
+	}
+
+	s.update(result)
+	if cs := s.checks["Check Foo"]; cs.Soft || cs.HardStatus != nagiosCritical || !cs.changed() {
+		t.Errorf("expected hard state change, got %+v", cs)
+	}
+
+	s.update(checkResult{name: "Check Foo", cached: true})
+	if cs := s.checks["Check Foo"]; cs.changed() {
+		t.Errorf("expected no change for cached result, got %+v", cs)
+	}
+
+	result.status = nagiosOk
+	s.update(result)
+	if cs := s.checks["Check Foo"]; cs.Soft || cs.Attempts != 0 || !cs.changed() {
+		t.Errorf("expected immediate hard recovery, got %+v", cs)
+	}
+}
+
+func TestReportUnreachable(t *testing.T) {
+	now := time.Now().Unix()
+	s := state{
+		checks: map[string]checkState{
+			"Ping":  {Status: nagiosCritical, HardStatus: nagiosCritical, PrevStatus: nagiosCritical, Epoch: now, Output: "down"},
+			"HTTP":  {Status: nagiosUnreachable, HardStatus: nagiosUnreachable, PrevStatus: nagiosOk, Epoch: now, RootCause: "Ping"},
+			"HTTPS": {Status: nagiosUnreachable, HardStatus: nagiosUnreachable, PrevStatus: nagiosUnreachable, Epoch: now, RootCause: "Ping"},
+		},
+		staleEpoch: now - 3600,
+	}
+
+	subject, body, _ := s.report(false, false)
+	if expected := "GOGIOS Report [C:1 W:0 U:0 S:0 OK:0]"; subject != expected {
+		t.Errorf("expected subject %q, got %q", expected, subject)
+	}
+	if !strings.Contains(body, "CRITICAL: Ping: down\n  UNREACHABLE: HTTP") {
+		t.Errorf("expected unreachable checks grouped below their root cause, got\n%s", body)
+	}
+	if strings.Contains(body, "OK->UNREACHABLE") {
+		t.Errorf("expected unreachable status changes not to be reported, got\n%s", body)
+	}
+}
+
+func TestReportUnreachableRecovery(t *testing.T) {
+	now := time.Now().Unix()
+	s := state{
+		checks: map[string]checkState{
+			"Ping":  {Status: nagiosOk, HardStatus: nagiosOk, PrevStatus: nagiosCritical, Epoch: now},
+			"HTTP":  {Status: nagiosOk, HardStatus: nagiosOk, PrevStatus: nagiosUnreachable, Epoch: now},
+			"HTTPS": {Status: nagiosCritical, HardStatus: nagiosCritical, PrevStatus: nagiosUnreachable, Epoch: now},
+		},
+		staleEpoch: now - 3600,
+	}
+
+	_, body, _ := s.report(false, false)
+	if !strings.Contains(body, "CRITICAL->OK: Ping") {
+		t.Errorf("expected the recovery of the root cause to be reported, got\n%s", body)
+	}
+	if strings.Contains(body, "UNREACHABLE->OK") {
+		t.Errorf("expected unreachable checks becoming OK not to be reported, got\n%s", body)
+	}
+	if !strings.Contains(body, "UNREACHABLE->CRITICAL: HTTPS") {
+		t.Errorf("expected unreachable checks failing on their own to be reported, got\n%s", body)
+	}
+	if changes := s.changes(); len(changes) != 2 {
+		t.Errorf("expected 2 changes, got %+v", changes)
+	}
+}
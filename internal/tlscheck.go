@@ -0,0 +1,216 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Protocols supported for STARTTLS by the TLS check
+const (
+	startTLSSMTP = "smtp"
+	startTLSIMAP = "imap"
+)
+
+// Built-in TLS certificate expiry check.
+type tlsCheck struct {
+	Host       string
+	Port       int    `json:"Port,omitempty"`       // Default 443
+	ServerName string `json:"ServerName,omitempty"` // SNI, default Host
+	StartTLS   string `json:"StartTLS,omitempty"`   // "smtp" or "imap"
+	CAFile     string `json:"CAFile,omitempty"`     // PEM roots instead of the system ones
+	WarnDays   int    `json:"WarnDays,omitempty"`   // Default 30
+	CritDays   int    `json:"CritDays,omitempty"`   // Default 14
+}
+
+func (t *tlsCheck) validate() error {
+	if t.Host == "" {
+		return errors.New("TLS check without Host")
+	}
+	switch t.StartTLS {
+	case "", startTLSSMTP, startTLSIMAP:
+	default:
+		return fmt.Errorf("unknown STARTTLS protocol '%s'", t.StartTLS)
+	}
+	if warnDays, critDays := t.thresholds(); warnDays < critDays {
+		return fmt.Errorf("WarnDays %d less than CritDays %d", warnDays, critDays)
+	}
+	if t.CAFile != "" {
+		if _, err := t.rootCAs(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The days left thresholds, with the defaults applied.
+func (t *tlsCheck) thresholds() (warnDays, critDays int) {
+	warnDays, critDays = t.WarnDays, t.CritDays
+	if warnDays == 0 {
+		warnDays = 30
+	}
+	if critDays == 0 {
+		critDays = 14
+	}
+	return warnDays, critDays
+}
+
+func (t *tlsCheck) run(ctx context.Context) checkResult {
+	port := t.Port
+	if port == 0 {
+		port = 443
+	}
+	warnDays, critDays := t.thresholds()
+	serverName := t.ServerName
+	if serverName == "" {
+		serverName = t.Host
+	}
+
+	rootCAs, err := t.rootCAs()
+	if err != nil {
+		return resultf(nagiosUnknown, "TLS UNKNOWN: %v", err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(t.Host, strconv.Itoa(port)))
+	if err != nil {
+		return resultf(nagiosCritical, "TLS CRITICAL: %v", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if err := startTLS(conn, t.StartTLS); err != nil {
+		return resultf(nagiosCritical, "TLS CRITICAL: STARTTLS: %v", err)
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: serverName, RootCAs: rootCAs})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return resultf(nagiosCritical, "TLS CRITICAL: %v", err)
+	}
+
+	// The certificate expiring first in the chain is the one that matters.
+	var expiring *x509.Certificate
+	for _, cert := range tlsConn.ConnectionState().VerifiedChains[0] {
+		if expiring == nil || cert.NotAfter.Before(expiring.NotAfter) {
+			expiring = cert
+		}
+	}
+
+	days := int(math.Floor(time.Until(expiring.NotAfter).Hours() / 24))
+	var status nagiosCode
+	switch {
+	case days < critDays:
+		status = nagiosCritical
+	case days < warnDays:
+		status = nagiosWarning
+	}
+
+	return checkResult{
+		status: status,
+		output: fmt.Sprintf("TLS %s: certificate '%s' expires in %d days (%s)", status.Str(),
+			expiring.Subject.CommonName, days, expiring.NotAfter.Format(time.DateOnly)),
+		perfData: perfData{{
+			Label: "days",
+			Value: float64(days),
+			Warn:  strconv.Itoa(warnDays) + ":",
+			Crit:  strconv.Itoa(critDays) + ":",
+		}},
+	}
+}
+
+func (t *tlsCheck) rootCAs() (*x509.CertPool, error) {
+	return loadRootCAs(t.CAFile)
+}
+
+// Returns nil for the system roots.
+func loadRootCAs(caFile string) (*x509.CertPool, error) {
+	if caFile == "" {
+		return nil, nil
+	}
+	bytes, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bytes) {
+		return nil, fmt.Errorf("no certificates found in '%s'", caFile)
+	}
+	return pool, nil
+}
+
+// Upgrade a plain text connection to TLS as in RFC 3207 (SMTP) or RFC 3501
+// (IMAP). Afterwards, the TLS handshake can start.
+func startTLS(conn net.Conn, protocol string) error {
+	reader := bufio.NewReader(conn)
+
+	switch protocol {
+	case startTLSSMTP:
+		if err := readSMTPReply(reader, "220"); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprint(conn, "EHLO gogios\r\n"); err != nil {
+			return err
+		}
+		if err := readSMTPReply(reader, "250"); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprint(conn, "STARTTLS\r\n"); err != nil {
+			return err
+		}
+		return readSMTPReply(reader, "220")
+
+	case startTLSIMAP:
+		greeting, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(greeting, "* OK") {
+			return fmt.Errorf("unexpected IMAP greeting '%s'", strings.TrimSpace(greeting))
+		}
+		if _, err := fmt.Fprint(conn, "a1 STARTTLS\r\n"); err != nil {
+			return err
+		}
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(line, "a1 ") {
+				if !strings.HasPrefix(line, "a1 OK") {
+					return fmt.Errorf("unexpected IMAP reply '%s'", strings.TrimSpace(line))
+				}
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// Read a (possibly multi-line) SMTP reply and expect the given code.
+func readSMTPReply(reader *bufio.Reader, code string) error {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if !strings.HasPrefix(line, code) {
+			return fmt.Errorf("unexpected SMTP reply '%s'", line)
+		}
+		if len(line) < 4 || line[3] != '-' {
+			return nil
+		}
+	}
+}
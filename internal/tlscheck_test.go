@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestTLSCheck(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, pemBytes, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	host, portStr, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	// A plain SMTP server upgrading the connection to TLS on STARTTLS.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			reader := bufio.NewReader(conn)
+			conn.Write([]byte("220 localhost ESMTP\r\n"))
+			reader.ReadString('\n')
+			conn.Write([]byte("250-localhost\r\n250 STARTTLS\r\n"))
+			reader.ReadString('\n')
+			conn.Write([]byte("220 Ready to start TLS\r\n"))
+			tlsConn := tls.Server(conn, server.TLS)
+			tlsConn.Handshake()
+			tlsConn.Close()
+		}
+	}()
+	smtpPort := listener.Addr().(*net.TCPAddr).Port
+
+	tests := []checkTest{
+		{
+			name:     "valid",
+			check:    check{TLS: &tlsCheck{Host: host, Port: port, ServerName: "example.com", CAFile: caFile}},
+			expected: nagiosOk,
+		},
+		{
+			name:     "expires soon",
+			check:    check{TLS: &tlsCheck{Host: host, Port: port, ServerName: "example.com", CAFile: caFile, WarnDays: 100000, CritDays: 100000}},
+			expected: nagiosCritical,
+			output:   "expires in",
+		},
+		{
+			name:     "unknown authority",
+			check:    check{TLS: &tlsCheck{Host: host, Port: port, ServerName: "example.com"}},
+			expected: nagiosCritical,
+			output:   "certificate",
+		},
+		{
+			name:     "wrong server name",
+			check:    check{TLS: &tlsCheck{Host: host, Port: port, ServerName: "foo.zone", CAFile: caFile}},
+			expected: nagiosCritical,
+			output:   "foo.zone",
+		},
+		{
+			name:     "STARTTLS",
+			check:    check{TLS: &tlsCheck{Host: "127.0.0.1", Port: smtpPort, ServerName: "example.com", CAFile: caFile, StartTLS: startTLSSMTP}},
+			expected: nagiosOk,
+		},
+	}
+
+	runCheckTests(t, tests)
+
+	if err := (&tlsCheck{Host: "foo.zone", WarnDays: 7, CritDays: 14}).validate(); err == nil {
+		t.Error("expected WarnDays below CritDays to be invalid")
+	}
+	if err := (&tlsCheck{Host: "foo.zone", WarnDays: 7}).validate(); err == nil {
+		t.Error("expected WarnDays below the default CritDays to be invalid")
+	}
+}
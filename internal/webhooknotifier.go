@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Payload types of the webhook notifier
+const (
+	webhookReportType = "report" // The whole report
+	webhookEventType  = "event"  // A single check changing its status
+)
+
+// Backoff before the first retry of a failed webhook request, doubled for
+// every further retry.
+var webhookBackoff = time.Second
+
+// Posts the reports, or one event per changed check, as JSON to a URL.
+type webhookNotifier struct {
+	Enable   bool
+	URL      string
+	Events   bool              `json:"Events,omitempty"`   // One request per changed check instead of the report
+	Template string            `json:"Template,omitempty"` // text/template of the body, default JSON
+	Headers  map[string]string `json:"Headers,omitempty"`
+	Token    string            `json:"Token,omitempty"`    // Bearer token
+	Retries  int               `json:"Retries,omitempty"`  // Default 3, -1 for none
+	TimeoutS int               `json:"TimeoutS,omitempty"` // Per request, default 10
+}
+
+// The JSON posted by the webhook notifier, also the data of the Template.
+type webhookPayload struct {
+	Type    string // "report" or "event"
+	Subject string
+	Body    string        `json:"Body,omitempty"`    // Only for reports
+	Changes []checkChange `json:"Changes,omitempty"` // Only for reports
+	Check   *checkChange  `json:"Check,omitempty"`   // Only for events
+}
+
+func (w *webhookNotifier) name() string {
+	return "webhook"
+}
+
+func (w *webhookNotifier) enabled() bool {
+	return w.Enable
+}
+
+func (w *webhookNotifier) validate() error {
+	if w.URL == "" {
+		return errors.New("webhook notifier without URL")
+	}
+	if w.Retries < -1 {
+		return errors.New("webhook notifier with invalid Retries")
+	}
+	if _, err := w.template(); err != nil {
+		return fmt.Errorf("webhook notifier: %w", err)
+	}
+	return nil
+}
+
+func (w *webhookNotifier) template() (*template.Template, error) {
+	if w.Template == "" {
+		return nil, nil
+	}
+	return template.New("webhook").Funcs(template.FuncMap{
+		// Quote a value for templates producing JSON, e.g. {{json .Subject}}
+		"json": func(v any) (string, error) {
+			bytes, err := json.Marshal(v)
+			return string(bytes), err
+		},
+	}).Parse(w.Template)
+}
+
+// Without changed checks (e.g. errors or forced reports), events mode falls
+// back to posting the report.
+func (w *webhookNotifier) notify(n notification) error {
+	if !w.Events || len(n.Changes) == 0 {
+		return w.post(webhookPayload{
+			Type:    webhookReportType,
+			Subject: n.Subject,
+			Body:    n.Body,
+			Changes: n.Changes,
+		})
+	}
+
+	var errs []error
+	for _, change := range n.Changes {
+		if err := w.post(webhookPayload{
+			Type:    webhookEventType,
+			Subject: n.Subject,
+			Check:   &change,
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", change.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (w *webhookNotifier) post(payload webhookPayload) error {
+	body, err := w.render(payload)
+	if err != nil {
+		return err
+	}
+	return w.deliver(body)
+}
+
+// Send the body, retrying with backoff. Also used by the push notifiers.
+func (w *webhookNotifier) deliver(body []byte) error {
+	retries := w.Retries
+	if retries == 0 {
+		retries = 3
+	}
+	backoff := webhookBackoff
+
+	for attempt := 0; ; attempt++ {
+		retryable, err := w.send(body)
+		if err == nil || !retryable || attempt >= retries {
+			return err
+		}
+		log.Printf("Retrying webhook %s in %v: %v", w.URL, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (w *webhookNotifier) render(payload webhookPayload) ([]byte, error) {
+	tmpl, err := w.template()
+	if err != nil {
+		return nil, err
+	}
+	if tmpl == nil {
+		return json.Marshal(payload)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, payload); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Send the request once, server errors and rate limiting are retryable.
+func (w *webhookNotifier) send(body []byte) (retryable bool, err error) {
+	timeout := time.Duration(w.TimeoutS) * time.Second
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.Token)
+	}
+	for key, value := range w.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+		return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+	}
+	return false, nil
+}
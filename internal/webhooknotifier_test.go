@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	backoff := webhookBackoff
+	webhookBackoff = time.Millisecond
+	t.Cleanup(func() { webhookBackoff = backoff })
+
+	var (
+		requests []string
+		failures int // Respond with 503 to this many requests
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("X-Source") != "gogios" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if failures > 0 {
+			failures--
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, string(body))
+	}))
+	defer server.Close()
+
+	s := state{checks: map[string]checkState{
+		"Check Ping": {Status: nagiosOk, PrevStatus: nagiosCritical, Output: "PING OK", Epoch: time.Now().Unix()},
+		"Check HTTP": {Status: nagiosCritical, PrevStatus: nagiosOk, Output: "HTTP CRITICAL", Epoch: time.Now().Unix()},
+		"Check Disk": {Status: nagiosWarning, PrevStatus: nagiosWarning, Output: "DISK WARNING", Epoch: time.Now().Unix()},
+	}}
+	n := notification{Subject: "GOGIOS Report [C:1 W:1 U:0 S:0 OK:1]", Body: "report", Changes: s.changes()}
+
+	webhook := webhookNotifier{
+		Enable:  true,
+		URL:     server.URL,
+		Token:   "secret",
+		Headers: map[string]string{"X-Source": "gogios"},
+	}
+	if err := webhook.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reports as JSON, retried on server errors.
+	failures = 2
+	if err := webhook.notify(n); err != nil {
+		t.Fatal(err)
+	}
+	var payload webhookPayload
+	if err := json.Unmarshal([]byte(requests[0]), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Type != webhookReportType || payload.Subject != n.Subject || payload.Body != "report" ||
+		len(payload.Changes) != 2 || payload.Changes[0].Name != "Check HTTP" ||
+		payload.Changes[0].PrevStatus != "OK" || payload.Changes[1].Status != "OK" {
+		t.Errorf("unexpected report payload: %+v", payload)
+	}
+
+	// Events with a template, one per changed check.
+	requests = nil
+	webhook.Events = true
+	webhook.Template = `{"text": {{json (printf "%s: %s" .Check.Name .Check.Output)}}}`
+	if err := webhook.notify(n); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{`{"text": "Check HTTP: HTTP CRITICAL"}`, `{"text": "Check Ping: PING OK"}`}
+	if strings.Join(requests, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected events %q, got %q", expected, requests)
+	}
+
+	// Give up after the retries, and don't retry client errors.
+	failures = 4
+	if err := webhook.notify(n); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("expected the retries to be exhausted, got %v", err)
+	}
+	failures = 0
+	webhook.Token = "wrong"
+	requests = nil
+	if err := webhook.notify(n); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected an authorization failure, got %v", err)
+	}
+
+	if err := (&webhookNotifier{URL: server.URL, Template: "{{.Foo"}).validate(); err == nil {
+		t.Error("expected an invalid template to fail")
+	}
+}